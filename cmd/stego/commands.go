@@ -0,0 +1,918 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"steganography-tool/internal/batch"
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+// 口令也可以通过环境变量传入，避免出现在进程列表中
+const passphraseEnv = "STEGO_PASSPHRASE"
+
+// 输出为JPEG且没有指定质量时使用的质量
+const defaultJPEGQuality = 90
+
+// 可重复出现的字符串参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// 创建子命令的参数集，错误信息输出到stderr
+func newFlagSet(name, synopsis string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "用法: stego %s\n\n参数:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// 解析参数，返回值非负时表示应立即退出
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "stego %s: 多余的参数 %q\n", fs.Name(), fs.Args())
+		return exitUsage
+	}
+	return -1
+}
+
+// 判断参数是否在命令行中显式指定
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// 检查必填参数
+func requireFlag(fs *flag.FlagSet, name, value string) int {
+	if value == "" {
+		fmt.Fprintf(fs.Output(), "stego %s: 缺少参数 -%s\n", fs.Name(), name)
+		fs.Usage()
+		return exitUsage
+	}
+	return -1
+}
+
+func runEmbed(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("embed", "embed -in 载体图片 -out 输出图片 [-msg 消息文件] [参数]", stderr)
+	in := fs.String("in", "", "载体图片路径，- 表示标准输入")
+	out := fs.String("out", "", "输出PNG图片路径，- 表示标准输出")
+	msg := fs.String("msg", "-", "要嵌入的消息文件，- 表示标准输入")
+	file := fs.String("file", "", "嵌入整个文件，同时保存文件名和内容类型，不能与 -msg 同时使用")
+	alg := fs.String("alg", "LSB", "隐写算法: "+strings.Join(steganography.Algorithms(), ", ")+"，载体为JPEG时还可使用 "+strings.Join(steganography.JPEGAlgorithms(), ", ")+"（输出JPEG）")
+	passphrase := fs.String("passphrase", "", "加密口令，也可通过环境变量 "+passphraseEnv+" 传入")
+	key := fs.String("key", "", "嵌入顺序密钥，提取时需提供相同密钥")
+	pad := fs.String("pad", "mirror", "尺寸不满足算法要求时的填充方式: none, edge, mirror")
+	compress := fs.Bool("compress", false, "嵌入前压缩负载")
+	ecc := fs.String("ecc", "none", "纠错等级: none, low, medium, high")
+	channels := fs.String("channels", "rgb", "LSB嵌入使用的通道，如 rgb, rgba, b")
+	bits := fs.Int("bits", 1, "LSB每个通道使用的最低位数（1–4）")
+	matching := fs.Bool("matching", false, "LSB使用±1匹配代替直接替换最低位，避免值对现象")
+	jpegQuality := fs.Int("jpeg-quality", 0, "DCT按该JPEG质量嵌入（50–90，5的倍数），输出以此质量以上保存为JPEG后仍可提取")
+	coefficients := fs.String("coefficients", "", "DCT嵌入使用的系数位置，以逗号分隔，如 4:3,3:4（可选 "+dctCoefficientNames()+"）")
+	bitsPerBlock := fs.Int("bits-per-block", 0, "DCT每块嵌入的比特数（1–8），按优先顺序使用中频系数")
+	strength := fs.Int("strength", 0, "DCT符号嵌入的强度（10, 15, 20, 25, 30, 40, 50），默认25")
+	qimStep := fs.Int("qim-step", 0, "DWT按该步长做QIM嵌入（4, 6, 8, 12, 16, 24, 32, 48, 64），步长越大越稳健、改动越大")
+	wavelet := fs.String("wavelet", "haar", "DWT使用的小波: haar, db4, cdf97")
+	levels := fs.Int("levels", 1, "DWT分解级数（1–4），级数越多越稳健、容量越小")
+	subbands := fs.String("subbands", "hl", "DWT嵌入的子带，以逗号分隔: lh, hl, hh")
+	selection := fs.String("select", "all", "DWT系数选择方式: all, textured（只用纹理和边缘处的系数）")
+	var recipients stringList
+	fs.Var(&recipients, "recipient", "接收者公钥（stegopub1...），可重复指定")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "in", *in); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "out", *out); code >= 0 {
+		return code
+	}
+	if *file != "" {
+		if isFlagSet(fs, "msg") {
+			fmt.Fprintln(stderr, "stego embed: -file 不能与 -msg 同时使用")
+			return exitUsage
+		}
+		*msg = ""
+	}
+	if *in == "-" && *msg == "-" {
+		fmt.Fprintln(stderr, "stego embed: 载体图片和消息不能同时来自标准输入")
+		return exitUsage
+	}
+
+	padMode, err := parsePadMode(*pad)
+	if err != nil {
+		fmt.Fprintf(stderr, "stego embed: %v\n", err)
+		return exitUsage
+	}
+	eccLevel, err := parseECCLevel(*ecc)
+	if err != nil {
+		fmt.Fprintf(stderr, "stego embed: %v\n", err)
+		return exitUsage
+	}
+	opts := &steganography.Options{
+		Passphrase: passphraseOrEnv(*passphrase),
+		Key:        *key,
+		Pad:        padMode,
+		Compress:   *compress,
+		ECC:        eccLevel,
+	}
+	for _, r := range recipients {
+		pub, err := steganography.ParsePublicKey(r)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego embed: %v\n", err)
+			return exitUsage
+		}
+		opts.Recipients = append(opts.Recipients, pub)
+	}
+
+	if jstego, err := steganography.NewJPEG(*alg); err == nil {
+		if code := rejectLSBFlags(fs, stderr); code >= 0 {
+			return code
+		}
+		if code := rejectDCTFlags(fs, stderr); code >= 0 {
+			return code
+		}
+		if code := rejectDWTFlags(fs, stderr); code >= 0 {
+			return code
+		}
+		return embedJPEG(jstego, *in, *out, *msg, *file, opts, stdin, stdout, stderr)
+	}
+	stego, err := steganography.New(*alg)
+	if err != nil {
+		fmt.Fprintf(stderr, "stego embed: %v\n", err)
+		return exitUsage
+	}
+	if lsb, ok := stego.(*steganography.LSB); ok {
+		ch, err := steganography.ParseChannels(*channels)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego embed: %v\n", err)
+			return exitUsage
+		}
+		lsb.Channels = ch
+		lsb.BitsPerChannel = *bits
+		lsb.Matching = *matching
+	} else if code := rejectLSBFlags(fs, stderr); code >= 0 {
+		return code
+	}
+	if dct, ok := stego.(*steganography.DCTSteganography); ok {
+		if *coefficients != "" {
+			coefs, err := steganography.ParseDCTCoefficients(*coefficients)
+			if err != nil {
+				fmt.Fprintf(stderr, "stego embed: %v\n", err)
+				return exitUsage
+			}
+			dct.Coefficients = coefs
+		}
+		dct.JPEGQuality = *jpegQuality
+		dct.BitsPerBlock = *bitsPerBlock
+		dct.Strength = *strength
+	} else if code := rejectDCTFlags(fs, stderr); code >= 0 {
+		return code
+	}
+	if dwt, ok := stego.(*steganography.DWTSteganography); ok {
+		w, err := steganography.ParseWavelet(*wavelet)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego embed: %v\n", err)
+			return exitUsage
+		}
+		bands, err := steganography.ParseSubbands(*subbands)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego embed: %v\n", err)
+			return exitUsage
+		}
+		sel, err := steganography.ParseSelection(*selection)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego embed: %v\n", err)
+			return exitUsage
+		}
+		dwt.QIMStep = *qimStep
+		dwt.Wavelet = w
+		dwt.Levels = *levels
+		dwt.Subbands = bands
+		dwt.Selection = sel
+	} else if code := rejectDWTFlags(fs, stderr); code >= 0 {
+		return code
+	}
+	if isJPEG(*out) && *jpegQuality == 0 {
+		fmt.Fprintln(stderr, "stego embed: 输出JPEG会破坏隐藏数据，请使用 -alg DCT -jpeg-quality")
+		return exitUsage
+	}
+	img, err := readImage(*in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	var encoded image.Image
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		if encoded, err = steganography.EmbedFile(stego, img, steganography.NewFile(*file, data), opts); err != nil {
+			return fail(stderr, err)
+		}
+	} else {
+		data, err := readInput(*msg, stdin)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		if encoded, err = steganography.Embed(stego, img, data, opts); err != nil {
+			return fail(stderr, err)
+		}
+	}
+	if err := writeImage(*out, stdout, encoded, *jpegQuality); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// LSB专用的参数与其他算法一起使用时报错
+func rejectLSBFlags(fs *flag.FlagSet, stderr io.Writer) int {
+	for _, name := range []string{"channels", "bits", "matching"} {
+		if isFlagSet(fs, name) {
+			fmt.Fprintf(stderr, "stego embed: -%s 只能与LSB算法一起使用\n", name)
+			return exitUsage
+		}
+	}
+	return -1
+}
+
+// DCT专用的参数与其他算法一起使用时报错
+func rejectDCTFlags(fs *flag.FlagSet, stderr io.Writer) int {
+	for _, name := range []string{"jpeg-quality", "coefficients", "bits-per-block", "strength"} {
+		if isFlagSet(fs, name) {
+			fmt.Fprintf(stderr, "stego embed: -%s 只能与DCT算法一起使用\n", name)
+			return exitUsage
+		}
+	}
+	return -1
+}
+
+// 可选的DCT系数位置，用于参数说明
+func dctCoefficientNames() string {
+	var names []string
+	for _, c := range steganography.DCTCoefficients() {
+		names = append(names, c.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// DWT专用的参数与其他算法一起使用时报错
+func rejectDWTFlags(fs *flag.FlagSet, stderr io.Writer) int {
+	for _, name := range []string{"qim-step", "wavelet", "levels", "subbands", "select"} {
+		if isFlagSet(fs, name) {
+			fmt.Fprintf(stderr, "stego embed: -%s 只能与DWT算法一起使用\n", name)
+			return exitUsage
+		}
+	}
+	return -1
+}
+
+// 用直接处理JPEG数据的算法嵌入，结果直接写出JPEG数据
+func embedJPEG(stego steganography.JPEGSteganographer, in, out, msg, file string, opts *steganography.Options, stdin io.Reader, stdout, stderr io.Writer) int {
+	cover, err := readInput(in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	var encoded []byte
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		if encoded, err = stego.EmbedFile(cover, steganography.NewFile(file, data), opts); err != nil {
+			return fail(stderr, err)
+		}
+	} else {
+		data, err := readInput(msg, stdin)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		if encoded, err = stego.Embed(cover, data, opts); err != nil {
+			return fail(stderr, err)
+		}
+	}
+	if err := writeOutput(out, stdout, encoded); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+func runExtract(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("extract", "extract -in 图片 [-out 输出文件] [参数]", stderr)
+	in := fs.String("in", "", "包含隐藏数据的图片路径，- 表示标准输入")
+	out := fs.String("out", "-", "提取结果的输出路径，- 表示标准输出")
+	save := fs.String("save", "", "嵌入的是文件时，按原文件名保存到此目录")
+	alg := fs.String("alg", "", "隐写算法，留空则自动识别")
+	passphrase := fs.String("passphrase", "", "解密口令，也可通过环境变量 "+passphraseEnv+" 传入")
+	key := fs.String("key", "", "嵌入顺序密钥")
+	confidence := fs.Bool("confidence", false, "报告各比特的判决置信度（仅DWT）")
+	var identities stringList
+	fs.Var(&identities, "identity", "私钥文件（每行一个stegosec1...），可重复指定")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "in", *in); code >= 0 {
+		return code
+	}
+
+	opts := &steganography.Options{
+		Passphrase: passphraseOrEnv(*passphrase),
+		Key:        *key,
+	}
+	for _, path := range identities {
+		keys, err := readIdentities(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego extract: %v\n", err)
+			return exitUsage
+		}
+		opts.Identities = append(opts.Identities, keys...)
+	}
+
+	data, err := readInput(*in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	// 自动识别时先尝试直接处理JPEG数据的算法，没有找到数据再按其他算法读取像素
+	name := *alg
+	if name == "" && isJPEGData(data) {
+		if name, _, err = steganography.InspectJPEG(data, opts); err != nil && !errors.Is(err, steganography.ErrNoHiddenData) {
+			return fail(stderr, err)
+		}
+	}
+	var f *steganography.File
+	if jstego, err := steganography.NewJPEG(name); err == nil {
+		if *confidence {
+			fmt.Fprintf(stderr, "stego extract: -confidence 不支持%s\n", name)
+			return exitUsage
+		}
+		if f, err = jstego.ExtractFile(data, opts); err != nil {
+			return fail(stderr, err)
+		}
+	} else {
+		img, err := decodeImage(*in, data)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		if name == "" {
+			if name, _, err = steganography.Inspect(img, opts); err != nil {
+				return fail(stderr, err)
+			}
+		}
+		stego, err := steganography.New(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego extract: %v\n", err)
+			return exitUsage
+		}
+		if *confidence {
+			var conf []float64
+			if f, conf, err = steganography.ExtractWithConfidence(stego, img, opts); err != nil {
+				return fail(stderr, err)
+			}
+			printConfidence(stderr, conf)
+		} else if f, err = steganography.ExtractFile(stego, img, opts); err != nil {
+			return fail(stderr, err)
+		}
+	}
+	if f.Corrected > 0 {
+		fmt.Fprintf(stderr, "已纠正 %d 个字节错误\n", f.Corrected)
+	}
+	if f.Name != "" {
+		fmt.Fprintf(stderr, "文件: %s (%s, %d 字节)\n", f.Name, f.ContentType, len(f.Data))
+		if *save != "" {
+			*out = filepath.Join(*save, f.Name)
+		}
+	}
+	if err := writeOutput(*out, stdout, f.Data); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+// 输出置信度的统计：平均值、最低值和接近判决边界的比特数
+func printConfidence(w io.Writer, conf []float64) {
+	if len(conf) == 0 {
+		return
+	}
+	sum, lowest, weak := 0.0, 1.0, 0
+	for _, c := range conf {
+		sum += c
+		lowest = min(lowest, c)
+		if c < 0.5 {
+			weak++
+		}
+	}
+	fmt.Fprintf(w, "置信度: 平均 %.3f，最低 %.3f，低于0.5的比特 %d/%d\n", sum/float64(len(conf)), lowest, weak, len(conf))
+}
+
+func runCapacity(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("capacity", "capacity -in 图片 [-alg 算法]", stderr)
+	in := fs.String("in", "", "图片路径，- 表示标准输入")
+	alg := fs.String("alg", "", "隐写算法，留空则显示所有算法")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "in", *in); code >= 0 {
+		return code
+	}
+
+	data, err := readInput(*in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if jstego, err := steganography.NewJPEG(*alg); err == nil {
+		capacity, err := jstego.Capacity(data)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		fmt.Fprintf(stdout, "%s\t%d\n", *alg, capacity)
+		return exitOK
+	}
+
+	names := steganography.Algorithms()
+	if *alg != "" {
+		names = []string{*alg}
+	}
+	img, err := decodeImage(*in, data)
+	if err != nil {
+		return fail(stderr, err)
+	}
+
+	bounds := img.Bounds()
+	for _, name := range names {
+		stego, err := steganography.New(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "stego capacity: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintf(stdout, "%s\t%d\n", name, stego.Capacity(bounds))
+	}
+	// 未指定算法时，JPEG还列出直接处理JPEG数据的算法的容量，不支持该JPEG的算法不列出
+	if *alg == "" && isJPEGData(data) {
+		for _, name := range steganography.JPEGAlgorithms() {
+			jstego, err := steganography.NewJPEG(name)
+			if err != nil {
+				return fail(stderr, err)
+			}
+			if capacity, err := jstego.Capacity(data); err == nil {
+				fmt.Fprintf(stdout, "%s\t%d\n", name, capacity)
+			}
+		}
+	}
+	return exitOK
+}
+
+func runInfo(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("info", "info -in 图片 [-key 密钥]", stderr)
+	in := fs.String("in", "", "图片路径，- 表示标准输入")
+	key := fs.String("key", "", "嵌入顺序密钥")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "in", *in); code >= 0 {
+		return code
+	}
+
+	data, err := readInput(*in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	opts := &steganography.Options{Key: *key}
+
+	var name string
+	var header *steganography.Header
+	if isJPEGData(data) {
+		name, header, err = steganography.InspectJPEG(data, opts)
+		if err != nil && !errors.Is(err, steganography.ErrNoHiddenData) {
+			return fail(stderr, err)
+		}
+	}
+	if header == nil {
+		img, err := decodeImage(*in, data)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		if name, header, err = steganography.Inspect(img, opts); err != nil {
+			return fail(stderr, err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "算法\t%s\n", name)
+	fmt.Fprintf(stdout, "版本\t%d\n", header.Version)
+	fmt.Fprintf(stdout, "参数\t%#x\n", header.Params)
+	fmt.Fprintf(stdout, "标志\t%s\n", describeFlags(header.Flags))
+	fmt.Fprintf(stdout, "长度\t%d 字节\n", header.Length)
+	if header.Flags&steganography.FlagPadded != 0 {
+		fmt.Fprintf(stdout, "原始尺寸\t%dx%d\n", header.OriginalWidth, header.OriginalHeight)
+	}
+	if header.ECC != steganography.ECCNone {
+		fmt.Fprintf(stdout, "纠错\t%s\n", header.ECC)
+		fmt.Fprintf(stdout, "已纠正\t%d 字节\n", header.Corrected)
+	}
+	return exitOK
+}
+
+// 水印命令共用的参数
+type watermarkFlags struct {
+	logo     *string
+	size     *string
+	key      *string
+	strength *float64
+	wavelet  *string
+	levels   *int
+}
+
+func addWatermarkFlags(fs *flag.FlagSet) *watermarkFlags {
+	return &watermarkFlags{
+		logo:     fs.String("logo", "", "水印logo图片，按亮度二值化，较暗的部分为前景"),
+		size:     fs.String("size", "32x32", "水印的宽x高，logo缩放到此尺寸"),
+		key:      fs.String("key", "", "水印密钥，检测时需提供相同密钥"),
+		strength: fs.Float64("strength", steganography.DefaultWatermarkStrength, "水印强度（亮度单位），越大越稳健、改动越大"),
+		wavelet:  fs.String("wavelet", "haar", "使用的小波: haar, db4, cdf97"),
+		levels:   fs.Int("levels", 2, "DWT分解级数（1–4）"),
+	}
+}
+
+// 按参数创建水印器，同时返回水印的宽高
+func (f *watermarkFlags) watermarker() (*steganography.DWTSVDWatermark, int, int, error) {
+	w, err := steganography.ParseWavelet(*f.wavelet)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(*f.size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return nil, 0, 0, fmt.Errorf("无效的水印尺寸 %q，格式为 宽x高", *f.size)
+	}
+	s := &steganography.DWTSVDWatermark{
+		Wavelet:  w,
+		Levels:   *f.levels,
+		Strength: *f.strength,
+		Key:      *f.key,
+	}
+	return s, width, height, nil
+}
+
+// 读取logo并生成参考水印
+func readWatermark(path string, width, height int, stdin io.Reader) (*steganography.Watermark, error) {
+	logo, err := readImage(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return steganography.WatermarkFromImage(logo, width, height)
+}
+
+func runWatermark(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("watermark", "watermark -in 图片 -out 输出图片 -logo logo图片 [参数]", stderr)
+	in := fs.String("in", "", "待加水印的图片路径，- 表示标准输入")
+	out := fs.String("out", "", "输出图片路径，- 表示标准输出（PNG）")
+	quality := fs.Int("quality", defaultJPEGQuality, "输出为JPEG时的质量")
+	wf := addWatermarkFlags(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	for _, required := range []struct{ name, value string }{{"in", *in}, {"out", *out}, {"logo", *wf.logo}} {
+		if code := requireFlag(fs, required.name, required.value); code >= 0 {
+			return code
+		}
+	}
+	s, width, height, err := wf.watermarker()
+	if err != nil {
+		fmt.Fprintf(stderr, "stego watermark: %v\n", err)
+		return exitUsage
+	}
+	if *in == "-" && *wf.logo == "-" {
+		fmt.Fprintln(stderr, "stego watermark: 图片和logo不能同时来自标准输入")
+		return exitUsage
+	}
+
+	mark, err := readWatermark(*wf.logo, width, height, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	img, err := readImage(*in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	marked, err := s.Embed(img, mark)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if err := writeImage(*out, stdout, marked, *quality); err != nil {
+		return fail(stderr, err)
+	}
+	return exitOK
+}
+
+func runDetect(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("detect", "detect -in 图片 -logo logo图片 [参数]", stderr)
+	in := fs.String("in", "", "待检测的图片路径，- 表示标准输入，可以经过缩放或重新压缩")
+	save := fs.String("save", "", "将提取的水印保存为图片，扩展名为.jpg时保存为JPEG，否则为PNG")
+	threshold := fs.Float64("threshold", 0.3, "相关系数不低于此值时认为存在水印")
+	wf := addWatermarkFlags(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "in", *in); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "logo", *wf.logo); code >= 0 {
+		return code
+	}
+	s, width, height, err := wf.watermarker()
+	if err != nil {
+		fmt.Fprintf(stderr, "stego detect: %v\n", err)
+		return exitUsage
+	}
+	if *in == "-" && *wf.logo == "-" {
+		fmt.Fprintln(stderr, "stego detect: 图片和logo不能同时来自标准输入")
+		return exitUsage
+	}
+	if *save == "-" {
+		fmt.Fprintln(stderr, "stego detect: 检测结果输出到标准输出，-save 不能为 -")
+		return exitUsage
+	}
+
+	mark, err := readWatermark(*wf.logo, width, height, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	img, err := readImage(*in, stdin)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	extracted, score, err := s.Detect(img, mark)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if *save != "" {
+		if err := writeImage(*save, stdout, extracted.Image(), defaultJPEGQuality); err != nil {
+			return fail(stderr, err)
+		}
+	}
+	fmt.Fprintf(stdout, "相关系数\t%.3f\n", score)
+	if score < *threshold {
+		fmt.Fprintln(stdout, "水印\t未检测到")
+		return exitNoData
+	}
+	fmt.Fprintln(stdout, "水印\t存在")
+	return exitOK
+}
+
+func runBatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("batch", "batch -in 输入目录 -out 输出目录 -template 模板 [参数]", stderr)
+	in := fs.String("in", "", "载体图片所在目录，递归处理子目录")
+	out := fs.String("out", "", "输出目录，保持与输入目录相同的结构")
+	template := fs.String("template", "", "负载模板，支持占位符 {name} {file} {path} {index}")
+	msg := fs.String("msg", "", "从文件读取负载模板，- 表示标准输入")
+	alg := fs.String("alg", "LSB", "隐写算法: "+strings.Join(steganography.Algorithms(), ", "))
+	passphrase := fs.String("passphrase", "", "加密口令，也可通过环境变量 "+passphraseEnv+" 传入")
+	key := fs.String("key", "", "嵌入顺序密钥")
+	pad := fs.String("pad", "mirror", "尺寸不满足算法要求时的填充方式: none, edge, mirror")
+	compress := fs.Bool("compress", false, "嵌入前压缩负载")
+	ecc := fs.String("ecc", "none", "纠错等级: none, low, medium, high")
+	workers := fs.Int("workers", 0, "并发数，0表示使用CPU核数")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "in", *in); code >= 0 {
+		return code
+	}
+	if code := requireFlag(fs, "out", *out); code >= 0 {
+		return code
+	}
+	if (*template == "") == (*msg == "") {
+		fmt.Fprintln(stderr, "stego batch: 必须指定 -template 或 -msg 中的一个")
+		return exitUsage
+	}
+
+	padMode, err := parsePadMode(*pad)
+	if err != nil {
+		fmt.Fprintf(stderr, "stego batch: %v\n", err)
+		return exitUsage
+	}
+	eccLevel, err := parseECCLevel(*ecc)
+	if err != nil {
+		fmt.Fprintf(stderr, "stego batch: %v\n", err)
+		return exitUsage
+	}
+	if *msg != "" {
+		data, err := readInput(*msg, stdin)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		*template = string(data)
+	}
+
+	// Ctrl+C 时停止派发新文件，并输出已完成部分的报告
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := batch.Run(ctx, batch.Config{
+		InputDir:  *in,
+		OutputDir: *out,
+		Algorithm: *alg,
+		Template:  *template,
+		Options: &steganography.Options{
+			Passphrase: passphraseOrEnv(*passphrase),
+			Key:        *key,
+			Pad:        padMode,
+			Compress:   *compress,
+			ECC:        eccLevel,
+		},
+		Workers: *workers,
+	})
+	if report != nil {
+		report.WriteTo(stdout)
+	}
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if !report.OK() {
+		return exitError
+	}
+	return exitOK
+}
+
+// 将标志位转换为可读的描述
+func describeFlags(flags uint8) string {
+	names := []struct {
+		flag uint8
+		name string
+	}{
+		{steganography.FlagEncrypted, "口令加密"},
+		{steganography.FlagRecipients, "接收者加密"},
+		{steganography.FlagPadded, "已填充"},
+		{steganography.FlagFile, "文件"},
+		{steganography.FlagCompressed, "已压缩"},
+	}
+
+	var parts []string
+	for _, n := range names {
+		if flags&n.flag != 0 {
+			parts = append(parts, n.name)
+			flags &^= n.flag
+		}
+	}
+	if flags != 0 {
+		parts = append(parts, fmt.Sprintf("未知(%#x)", flags))
+	}
+	if len(parts) == 0 {
+		return "无"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func parsePadMode(s string) (steganography.PadMode, error) {
+	switch s {
+	case "none":
+		return steganography.PadNone, nil
+	case "edge":
+		return steganography.PadEdge, nil
+	case "mirror":
+		return steganography.PadMirror, nil
+	}
+	return steganography.PadNone, fmt.Errorf("未知的填充方式: %s", s)
+}
+
+func parseECCLevel(s string) (steganography.ECCLevel, error) {
+	for _, level := range []steganography.ECCLevel{
+		steganography.ECCNone, steganography.ECCLow, steganography.ECCMedium, steganography.ECCHigh,
+	} {
+		if s == level.String() {
+			return level, nil
+		}
+	}
+	return steganography.ECCNone, fmt.Errorf("未知的纠错等级: %s", s)
+}
+
+func passphraseOrEnv(passphrase string) string {
+	if passphrase != "" {
+		return passphrase
+	}
+	return os.Getenv(passphraseEnv)
+}
+
+// 读取私钥文件，忽略空行和#开头的注释
+func readIdentities(path string) ([]*steganography.PrivateKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []*steganography.PrivateKey
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := steganography.ParsePrivateKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: 没有找到私钥", path)
+	}
+	return keys, nil
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func readImage(path string, stdin io.Reader) (image.Image, error) {
+	data, err := readInput(path, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(path, data)
+}
+
+func decodeImage(path string, data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("无法解码图片 %s: %w", path, err)
+	}
+	return img, nil
+}
+
+func writeOutput(path string, stdout io.Writer, data []byte) error {
+	if path == "-" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// 扩展名为.jpg或.jpeg时按给定质量保存为JPEG，否则保存为PNG
+func writeImage(path string, stdout io.Writer, img image.Image, jpegQuality int) error {
+	if path == "-" {
+		return png.Encode(stdout, img)
+	}
+	encode := png.Encode
+	if isJPEG(path) {
+		encode = func(w io.Writer, img image.Image) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func isJPEG(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// 按文件头判断数据是否为JPEG
+func isJPEGData(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xff, 0xd8})
+}
@@ -0,0 +1,86 @@
+// stego 是图像隐写工具的命令行版本，不依赖图形界面，可用于脚本和无界面的服务器
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+// 退出码
+const (
+	exitOK         = 0
+	exitError      = 1 // 一般错误，如文件读写失败、容量不足
+	exitUsage      = 2 // 命令行参数错误
+	exitNoData     = 3 // 图片中没有隐藏数据
+	exitCorrupted  = 4 // 隐藏数据已损坏
+	exitAuthFailed = 5 // 缺少口令、口令错误或没有匹配的私钥
+)
+
+const usage = `用法: stego <命令> [参数]
+
+命令:
+  embed     将消息嵌入图片
+  extract   从图片中提取消息
+  capacity  显示图片在各算法下的可嵌入容量
+  info      显示图片中隐藏数据的负载头信息
+  batch     将消息批量嵌入目录中的所有图片
+  watermark 在图片中嵌入稳健的logo水印
+  detect    检测图片中的logo水印，报告相关系数
+
+使用 "stego <命令> -h" 查看命令的参数
+`
+
+// 子命令，返回值为退出码
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"embed":     runEmbed,
+	"extract":   runExtract,
+	"capacity":  runCapacity,
+	"info":      runInfo,
+	"batch":     runBatch,
+	"watermark": runWatermark,
+	"detect":    runDetect,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "stego: 未知命令 %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+// 输出错误信息并返回对应的退出码
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "stego: %v\n", err)
+	switch {
+	case errors.Is(err, steganography.ErrNoHiddenData):
+		return exitNoData
+	case errors.Is(err, steganography.ErrPassphraseRequired),
+		errors.Is(err, steganography.ErrWrongPassphrase),
+		errors.Is(err, steganography.ErrNotRecipient):
+		return exitAuthFailed
+	case errors.Is(err, steganography.ErrCorrupted):
+		return exitCorrupted
+	}
+	return exitError
+}
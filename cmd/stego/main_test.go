@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 在临时目录中写入一张渐变测试图片
+func writeTestImage(t *testing.T, width, height int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x + y), 255})
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "cover.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCLI(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI_EmbedExtract(t *testing.T) {
+	cover := writeTestImage(t, 403, 301)
+	message := "命令行隐写测试\n"
+
+	for _, alg := range []string{"LSB", "DCT", "DWT"} {
+		t.Run(alg, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "stego.png")
+			code, _, stderr := runCLI(message, "embed", "-in", cover, "-out", out, "-alg", alg, "-passphrase", "pw")
+			if code != exitOK {
+				t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+			}
+
+			// 不指定算法时自动识别
+			code, stdout, stderr := runCLI("", "extract", "-in", out, "-passphrase", "pw")
+			if code != exitOK {
+				t.Fatalf("extract exit code = %d; stderr: %s", code, stderr)
+			}
+			if stdout != message {
+				t.Errorf("extract output = %q; want %q", stdout, message)
+			}
+
+			code, _, _ = runCLI("", "extract", "-in", out, "-alg", alg, "-passphrase", "wrong")
+			if code != exitAuthFailed {
+				t.Errorf("extract with wrong passphrase exit code = %d; want %d", code, exitAuthFailed)
+			}
+
+			code, stdout, _ = runCLI("", "info", "-in", out)
+			if code != exitOK || !strings.Contains(stdout, alg) || !strings.Contains(stdout, "口令加密") {
+				t.Errorf("info exit code = %d, output:\n%s", code, stdout)
+			}
+		})
+	}
+}
+
+func TestCLI_Capacity(t *testing.T) {
+	cover := writeTestImage(t, 256, 256)
+	code, stdout, stderr := runCLI("", "capacity", "-in", cover)
+	if code != exitOK {
+		t.Fatalf("capacity exit code = %d; stderr: %s", code, stderr)
+	}
+	for _, want := range []string{"LSB\t24559\n", "DCT\t111\n", "DWT\t111\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("capacity output missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestCLI_ExitCodes(t *testing.T) {
+	cover := writeTestImage(t, 64, 64)
+	large := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(large, make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"无命令", nil, exitUsage},
+		{"未知命令", []string{"unknown"}, exitUsage},
+		{"帮助", []string{"embed", "-h"}, exitOK},
+		{"缺少参数", []string{"extract"}, exitUsage},
+		{"未知算法", []string{"embed", "-in", cover, "-out", "-", "-alg", "XYZ"}, exitUsage},
+		{"文件不存在", []string{"info", "-in", filepath.Join(t.TempDir(), "missing.png")}, exitError},
+		{"没有隐藏数据", []string{"extract", "-in", cover, "-alg", "LSB"}, exitNoData},
+		{"没有隐藏数据（自动识别）", []string{"info", "-in", cover}, exitNoData},
+		{"超出容量", []string{"embed", "-in", cover, "-out", "-", "-file", large}, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := runCLI("", tt.args...); code != tt.want {
+				t.Errorf("exit code = %d; want %d; stderr: %s", code, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestCLI_Batch(t *testing.T) {
+	cover := writeTestImage(t, 64, 64)
+	in := filepath.Dir(cover)
+	out := filepath.Join(t.TempDir(), "out")
+
+	code, stdout, stderr := runCLI("", "batch", "-in", in, "-out", out, "-template", "ID {name}")
+	if code != exitOK {
+		t.Fatalf("batch exit code = %d; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "成功 1") {
+		t.Errorf("batch output = %q", stdout)
+	}
+
+	code, stdout, stderr = runCLI("", "extract", "-in", filepath.Join(out, "cover.png"))
+	if code != exitOK || stdout != "ID cover" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI("", "batch", "-in", in, "-out", out); code != exitUsage {
+		t.Errorf("batch without template exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_File(t *testing.T) {
+	cover := writeTestImage(t, 128, 128)
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "key.pem")
+	content := "-----BEGIN KEY-----\nabc\n-----END KEY-----\n"
+	if err := os.WriteFile(secret, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "stego.png")
+	if code, _, stderr := runCLI("", "embed", "-in", cover, "-out", out, "-file", secret); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+
+	saveDir := t.TempDir()
+	code, _, stderr := runCLI("", "extract", "-in", out, "-save", saveDir)
+	if code != exitOK {
+		t.Fatalf("extract exit code = %d; stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "key.pem") {
+		t.Errorf("extract stderr = %q; want file name", stderr)
+	}
+	got, err := os.ReadFile(filepath.Join(saveDir, "key.pem"))
+	if err != nil {
+		t.Fatalf("read saved file: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("saved file = %q; want %q", got, content)
+	}
+
+	if code, _, _ := runCLI("", "embed", "-in", cover, "-out", out, "-file", secret, "-msg", secret); code != exitUsage {
+		t.Errorf("embed with -file and -msg exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_ECC(t *testing.T) {
+	cover := writeTestImage(t, 128, 128)
+	out := filepath.Join(t.TempDir(), "stego.png")
+	if code, _, stderr := runCLI("纠错测试", "embed", "-in", cover, "-out", out, "-ecc", "high"); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI("", "extract", "-in", out)
+	if code != exitOK || stdout != "纠错测试" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	code, stdout, _ = runCLI("", "info", "-in", out)
+	if code != exitOK || !strings.Contains(stdout, "纠错\thigh") {
+		t.Errorf("info exit code = %d, output:\n%s", code, stdout)
+	}
+
+	if code, _, _ := runCLI("", "embed", "-in", cover, "-out", out, "-ecc", "max"); code != exitUsage {
+		t.Errorf("embed with unknown ECC level exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_JPEGOutput(t *testing.T) {
+	cover := writeTestImage(t, 256, 256)
+	out := filepath.Join(t.TempDir(), "secret.jpg")
+	code, _, stderr := runCLI("JPEG输出", "embed", "-in", cover, "-out", out, "-alg", "DCT", "-jpeg-quality", "75")
+	if code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI("", "extract", "-in", out, "-alg", "DCT")
+	if code != exitOK || stdout != "JPEG输出" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	// 未指定质量时输出JPEG会破坏数据，-jpeg-quality 只适用于DCT
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DCT"); code != exitUsage {
+		t.Errorf("embed to JPEG without -jpeg-quality exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-jpeg-quality", "75"); code != exitUsage {
+		t.Errorf("embed with LSB and -jpeg-quality exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_F5(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			img.Set(x, y, color.RGBA{uint8(x*7 ^ y*13), uint8(y * 2), uint8(x ^ y), 255})
+		}
+	}
+	dir := t.TempDir()
+	cover := filepath.Join(dir, "cover.jpg")
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cover, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "secret.jpg")
+	code, _, stderr := runCLI("系数域隐写", "embed", "-in", cover, "-out", out, "-alg", "F5", "-key", "k")
+	if code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+
+	// 自动识别时先尝试F5
+	code, stdout, stderr := runCLI("", "extract", "-in", out, "-key", "k")
+	if code != exitOK || stdout != "系数域隐写" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+	code, stdout, _ = runCLI("", "info", "-in", out, "-key", "k")
+	if code != exitOK || !strings.Contains(stdout, "算法\tF5") {
+		t.Errorf("info exit code = %d, output:\n%s", code, stdout)
+	}
+	code, stdout, _ = runCLI("", "capacity", "-in", cover)
+	if code != exitOK || !strings.Contains(stdout, "F5\t") {
+		t.Errorf("capacity exit code = %d, output:\n%s", code, stdout)
+	}
+
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "F5", "-jpeg-quality", "75"); code != exitUsage {
+		t.Errorf("embed with F5 and -jpeg-quality exit code = %d; want %d", code, exitUsage)
+	}
+	// PNG不能作为F5的载体
+	pngCover := writeTestImage(t, 64, 64)
+	if code, _, _ := runCLI("x", "embed", "-in", pngCover, "-out", out, "-alg", "F5"); code != exitError {
+		t.Errorf("embed PNG with F5 exit code = %d; want %d", code, exitError)
+	}
+}
+
+func TestCLI_LSBConfig(t *testing.T) {
+	cover := writeTestImage(t, 64, 64)
+	out := filepath.Join(t.TempDir(), "stego.png")
+	if code, _, stderr := runCLI("四个通道", "embed", "-in", cover, "-out", out, "-channels", "rgba", "-bits", "2"); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+	if code, stdout, stderr := runCLI("", "info", "-in", out); code != exitOK || !strings.Contains(stdout, "0x20f") {
+		t.Errorf("info exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+	if code, stdout, stderr := runCLI("", "extract", "-in", out); code != exitOK || stdout != "四个通道" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-channels", "rgbx"); code != exitUsage {
+		t.Errorf("embed with unknown channel exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-bits", "5"); code != exitError {
+		t.Errorf("embed with 5 bits exit code = %d; want %d", code, exitError)
+	}
+	for _, flag := range []string{"-channels=r", "-bits=1", "-matching"} {
+		if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DWT", flag); code != exitUsage {
+			t.Errorf("embed with DWT and %s exit code = %d; want %d", flag, code, exitUsage)
+		}
+	}
+}
+
+func TestCLI_LSBMatching(t *testing.T) {
+	cover := writeTestImage(t, 64, 64)
+	dir := t.TempDir()
+	replaced, matched := filepath.Join(dir, "replaced.png"), filepath.Join(dir, "matched.png")
+	for _, args := range [][]string{
+		{"embed", "-in", cover, "-out", replaced, "-key", "k"},
+		{"embed", "-in", cover, "-out", matched, "-key", "k", "-matching"},
+	} {
+		if code, _, stderr := runCLI("±1匹配", args...); code != exitOK {
+			t.Fatalf("%v exit code = %d; stderr: %s", args, code, stderr)
+		}
+	}
+	if code, stdout, stderr := runCLI("", "extract", "-in", matched, "-key", "k"); code != exitOK || stdout != "±1匹配" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	// 同一密钥下两种方式改动的像素值不同
+	a, err := os.ReadFile(replaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(matched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("-matching produced the same image as replacement")
+	}
+}
+
+func TestCLI_QIM(t *testing.T) {
+	cover := writeTestImage(t, 256, 256)
+	out := filepath.Join(t.TempDir(), "stego.png")
+	if code, _, stderr := runCLI("量化索引调制", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-qim-step", "16"); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI("", "extract", "-in", out, "-confidence")
+	if code != exitOK || stdout != "量化索引调制" || !strings.Contains(stderr, "置信度") {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-qim-step", "16"); code != exitUsage {
+		t.Errorf("embed with LSB and -qim-step exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-qim-step", "5"); code != exitError {
+		t.Errorf("embed with unsupported step exit code = %d; want %d", code, exitError)
+	}
+}
+
+func TestCLI_Wavelet(t *testing.T) {
+	cover := writeTestImage(t, 256, 256)
+	out := filepath.Join(t.TempDir(), "stego.png")
+	if code, _, stderr := runCLI("多级小波", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-wavelet", "cdf97", "-levels", "2"); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+	if code, stdout, stderr := runCLI("", "extract", "-in", out); code != exitOK || stdout != "多级小波" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-wavelet", "db8"); code != exitUsage {
+		t.Errorf("embed with unknown wavelet exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-levels", "2"); code != exitUsage {
+		t.Errorf("embed with LSB and -levels exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_Subbands(t *testing.T) {
+	cover := writeTestImage(t, 256, 256)
+	out := filepath.Join(t.TempDir(), "stego.png")
+	if code, _, stderr := runCLI("三个子带", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-subbands", "lh,hl,hh"); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+	if code, stdout, stderr := runCLI("", "extract", "-in", out); code != exitOK || stdout != "三个子带" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	// 渐变图像没有纹理，放不下数据
+	if code, _, stderr := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-select", "textured"); code != exitError {
+		t.Errorf("embed textured into gradient exit code = %d; want %d; stderr: %s", code, exitError, stderr)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DWT", "-subbands", "ll"); code != exitUsage {
+		t.Errorf("embed with unknown subband exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-select", "textured"); code != exitUsage {
+		t.Errorf("embed with LSB and -select exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_Watermark(t *testing.T) {
+	cover := writeTestImage(t, 512, 512)
+	dir := t.TempDir()
+
+	// 左半边黑色的logo
+	logoImg := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range logoImg.Pix {
+		if i%64 >= 32 {
+			logoImg.Pix[i] = 255
+		}
+	}
+	logo := filepath.Join(dir, "logo.png")
+	f, err := os.Create(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, logoImg); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	marked := filepath.Join(dir, "marked.jpg")
+	if code, _, stderr := runCLI("", "watermark", "-in", cover, "-out", marked, "-logo", logo, "-key", "k"); code != exitOK {
+		t.Fatalf("watermark exit code = %d; stderr: %s", code, stderr)
+	}
+	code, stdout, stderr := runCLI("", "detect", "-in", marked, "-logo", logo, "-key", "k", "-save", filepath.Join(dir, "extracted.png"))
+	if code != exitOK || !strings.Contains(stdout, "存在") {
+		t.Errorf("detect exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "extracted.png")); err != nil {
+		t.Errorf("extracted watermark not saved: %v", err)
+	}
+
+	// 保存为JPEG时使用默认质量，黑白分明的水印解码后与PNG相同
+	saved := filepath.Join(dir, "extracted.jpg")
+	if code, _, stderr := runCLI("", "detect", "-in", marked, "-logo", logo, "-key", "k", "-save", saved); code != exitOK {
+		t.Fatalf("detect -save .jpg exit code = %d; stderr: %s", code, stderr)
+	}
+	decode := func(path string, decoder func(io.Reader) (image.Image, error)) image.Image {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		img, err := decoder(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	want, got := decode(filepath.Join(dir, "extracted.png"), png.Decode), decode(saved, jpeg.Decode)
+	bounds := want.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			w := int(color.GrayModel.Convert(want.At(x, y)).(color.Gray).Y)
+			g := int(color.GrayModel.Convert(got.At(x, y)).(color.Gray).Y)
+			if w != g {
+				t.Fatalf("saved JPEG pixel (%d, %d) = %d; PNG %d", x, y, g, w)
+			}
+		}
+	}
+
+	// 报告写到标准输出，不能与图片数据混在一起
+	if code, _, _ := runCLI("", "detect", "-in", marked, "-logo", logo, "-key", "k", "-save", "-"); code != exitUsage {
+		t.Errorf("detect -save - exit code = %d; want %d", code, exitUsage)
+	}
+
+	if code, stdout, _ := runCLI("", "detect", "-in", cover, "-logo", logo, "-key", "k"); code != exitNoData {
+		t.Errorf("detect on unmarked image exit code = %d; want %d; output = %q", code, exitNoData, stdout)
+	}
+	if code, _, _ := runCLI("", "watermark", "-in", cover, "-out", marked); code != exitUsage {
+		t.Errorf("watermark without -logo exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("", "detect", "-in", marked, "-logo", logo, "-size", "32"); code != exitUsage {
+		t.Errorf("detect with invalid -size exit code = %d; want %d", code, exitUsage)
+	}
+}
+
+func TestCLI_DCTCoefficients(t *testing.T) {
+	cover := writeTestImage(t, 256, 256)
+	out := filepath.Join(t.TempDir(), "stego.png")
+	if code, _, stderr := runCLI("多个系数", "embed", "-in", cover, "-out", out, "-alg", "DCT", "-coefficients", "4:3,2:5", "-strength", "15"); code != exitOK {
+		t.Fatalf("embed exit code = %d; stderr: %s", code, stderr)
+	}
+	if code, stdout, stderr := runCLI("", "extract", "-in", out); code != exitOK || stdout != "多个系数" {
+		t.Errorf("extract exit code = %d, output = %q; stderr: %s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DCT", "-coefficients", "0:0"); code == exitOK {
+		t.Error("embed with unsupported coefficient succeeded")
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-alg", "DCT", "-coefficients", "43"); code != exitUsage {
+		t.Errorf("embed with malformed -coefficients exit code = %d; want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI("x", "embed", "-in", cover, "-out", out, "-bits-per-block", "2"); code != exitUsage {
+		t.Errorf("embed with LSB and -bits-per-block exit code = %d; want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,333 @@
+// Package batch 将负载批量嵌入目录中的所有图片，命令行和图形界面共用
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+// Config 描述一次批量嵌入任务
+type Config struct {
+	// InputDir 载体图片所在目录，会递归处理子目录
+	InputDir string
+	// OutputDir 输出目录，保持与输入目录相同的结构，输出均为PNG
+	OutputDir string
+	// Algorithm 隐写算法名称
+	Algorithm string
+	// Template 负载模板，支持以下占位符：
+	//	{name}  不含扩展名的文件名
+	//	{file}  含扩展名的文件名
+	//	{path}  相对输入目录的路径
+	//	{index} 按路径排序后的序号，从1开始
+	Template string
+	// Options 嵌入选项，所有文件共用
+	Options *steganography.Options
+	// Workers 并发数，0表示使用CPU核数
+	Workers int
+	// Progress 每处理完一个文件调用一次，可能在任意goroutine中调用
+	Progress func(done, total int)
+}
+
+// Status 表示单个文件的处理结果
+type Status int
+
+const (
+	// StatusOK 嵌入成功
+	StatusOK Status = iota
+	// StatusTooLarge 负载超出图片容量
+	StatusTooLarge
+	// StatusSkipped 不是支持的图片格式，未处理
+	StatusSkipped
+	// StatusFailed 读取、嵌入或写入失败
+	StatusFailed
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "成功"
+	case StatusTooLarge:
+		return "容量不足"
+	case StatusSkipped:
+		return "跳过"
+	case StatusFailed:
+		return "失败"
+	}
+	return "Status(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Result 是单个文件的处理结果
+type Result struct {
+	// Path 相对输入目录的路径
+	Path string
+	// Output 输出文件路径，仅在成功时有效
+	Output string
+	Status Status
+	Err    error
+}
+
+// Report 汇总所有文件的处理结果，按路径排序
+type Report struct {
+	Results []Result
+}
+
+// Count 返回处于给定状态的文件数
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// OK 在没有容量不足或失败的文件时返回true
+func (r *Report) OK() bool {
+	return r.Count(StatusTooLarge) == 0 && r.Count(StatusFailed) == 0
+}
+
+// Summary 返回一行统计信息
+func (r *Report) Summary() string {
+	return fmt.Sprintf("共 %d 个文件：成功 %d，容量不足 %d，跳过 %d，失败 %d",
+		len(r.Results), r.Count(StatusOK), r.Count(StatusTooLarge), r.Count(StatusSkipped), r.Count(StatusFailed))
+}
+
+// WriteTo 输出未成功的文件及原因，最后输出统计信息
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	for _, res := range r.Results {
+		if res.Status == StatusOK {
+			continue
+		}
+		if res.Err != nil {
+			fmt.Fprintf(&sb, "%s\t%s\t%v\n", res.Status, res.Path, res.Err)
+		} else {
+			fmt.Fprintf(&sb, "%s\t%s\n", res.Status, res.Path)
+		}
+	}
+	sb.WriteString(r.Summary())
+	sb.WriteByte('\n')
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// 支持的载体图片扩展名
+var imageExts = []string{".png", ".jpg", ".jpeg"}
+
+// Run 按配置处理输入目录中的所有文件。单个文件的错误记录在报告中，
+// 只有配置错误或ctx被取消时才返回错误，取消时同时返回已完成部分的报告
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	stego, err := steganography.New(cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.InputDir == "" || cfg.OutputDir == "" {
+		return nil, errors.New("必须指定输入和输出目录")
+	}
+
+	paths, err := listFiles(cfg.InputDir, cfg.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	// 不同扩展名的同名图片会输出到同一个文件，只处理排在前面的那个
+	outputs := make(map[string]string)
+	conflicts := make(map[int]string)
+	for i, rel := range paths {
+		if !isImage(rel) {
+			continue
+		}
+		out := outputPath(rel)
+		if first, ok := outputs[out]; ok {
+			conflicts[i] = first
+			continue
+		}
+		outputs[out] = rel
+	}
+
+	results := make([]Result, len(paths))
+	jobs := make(chan int)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if first, ok := conflicts[i]; ok {
+					results[i] = Result{
+						Path:   filepath.ToSlash(paths[i]),
+						Status: StatusFailed,
+						Err:    fmt.Errorf("输出文件与 %s 重名", filepath.ToSlash(first)),
+					}
+				} else {
+					results[i] = process(stego, cfg, paths, i)
+				}
+				if cfg.Progress != nil {
+					mu.Lock()
+					done++
+					cfg.Progress(done, len(paths))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	// 取消时停止派发，已派发的文件会处理完
+	dispatched := 0
+dispatch:
+	for i := range paths {
+		select {
+		case jobs <- i:
+			dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &Report{Results: results[:dispatched]}
+	return report, ctx.Err()
+}
+
+// 递归列出输入目录中的文件，跳过输出目录，返回按路径排序的相对路径
+func listFiles(inputDir, outputDir string) ([]string, error) {
+	absOut, err := filepath.Abs(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	err = filepath.WalkDir(inputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// 输出目录位于输入目录内时不要再处理输出结果
+			if abs, err := filepath.Abs(path); err == nil && abs == absOut {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// 处理第i个文件
+func process(stego steganography.Steganographer, cfg Config, paths []string, i int) Result {
+	rel := paths[i]
+	res := Result{Path: filepath.ToSlash(rel)}
+
+	if !isImage(rel) {
+		res.Status = StatusSkipped
+		return res
+	}
+
+	img, err := readImage(filepath.Join(cfg.InputDir, rel))
+	if err != nil {
+		res.Status, res.Err = StatusFailed, err
+		return res
+	}
+
+	payload := expandTemplate(cfg.Template, rel, i+1)
+	encoded, err := steganography.Embed(stego, img, []byte(payload), cfg.Options)
+	if err != nil {
+		res.Status, res.Err = StatusFailed, err
+		if errors.Is(err, steganography.ErrTooLarge) {
+			res.Status = StatusTooLarge
+		}
+		return res
+	}
+
+	out := filepath.Join(cfg.OutputDir, outputPath(rel))
+	if err := writeImage(out, encoded); err != nil {
+		res.Status, res.Err = StatusFailed, err
+		return res
+	}
+	res.Output = out
+	return res
+}
+
+func isImage(rel string) bool {
+	return slices.Contains(imageExts, strings.ToLower(filepath.Ext(rel)))
+}
+
+// 输出文件相对输出目录的路径，扩展名统一为.png
+func outputPath(rel string) string {
+	return strings.TrimSuffix(rel, filepath.Ext(rel)) + ".png"
+}
+
+// 替换模板中的占位符
+func expandTemplate(template, rel string, index int) string {
+	file := filepath.Base(rel)
+	return strings.NewReplacer(
+		"{name}", strings.TrimSuffix(file, filepath.Ext(file)),
+		"{file}", file,
+		"{path}", filepath.ToSlash(rel),
+		"{index}", strconv.Itoa(index),
+	).Replace(template)
+}
+
+func readImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("无法解码图片: %w", err)
+	}
+	return img, nil
+}
+
+func writeImage(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,158 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+func newTestImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x + y), 255})
+		}
+	}
+	return img
+}
+
+// 在目录中写入测试文件，按扩展名选择编码方式
+func writeFile(t *testing.T, path string, img image.Image, raw string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	switch {
+	case img == nil:
+		_, err = f.WriteString(raw)
+	case strings.HasSuffix(path, ".jpg"):
+		err = jpeg.Encode(f, img, nil)
+	default:
+		err = png.Encode(f, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	in := t.TempDir()
+	out := filepath.Join(in, "out") // 输出目录位于输入目录内时不应被再次处理
+	writeFile(t, filepath.Join(in, "a.png"), newTestImage(64, 64), "")
+	writeFile(t, filepath.Join(in, "sub", "b.jpg"), newTestImage(80, 48), "")
+	writeFile(t, filepath.Join(in, "sub", "tiny.png"), newTestImage(4, 4), "")
+	writeFile(t, filepath.Join(in, "notes.txt"), nil, "not an image")
+	writeFile(t, filepath.Join(in, "broken.png"), nil, "not a png")
+	writeFile(t, filepath.Join(in, "a.jpeg"), newTestImage(64, 64), "")
+
+	var progress atomic.Int32
+	report, err := Run(context.Background(), Config{
+		InputDir:  in,
+		OutputDir: out,
+		Algorithm: "LSB",
+		Template:  "ID={name} path={path} #{index}",
+		Options:   &steganography.Options{Key: "batch"},
+		Workers:   3,
+		Progress:  func(done, total int) { progress.Add(1) },
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := map[string]Status{
+		"a.jpeg":       StatusOK,
+		"a.png":        StatusFailed, // 与a.jpeg输出到同一个文件
+		"broken.png":   StatusFailed,
+		"notes.txt":    StatusSkipped,
+		"sub/b.jpg":    StatusOK,
+		"sub/tiny.png": StatusTooLarge,
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("Run() returned %d results; want %d: %+v", len(report.Results), len(want), report.Results)
+	}
+	for _, res := range report.Results {
+		if res.Status != want[res.Path] {
+			t.Errorf("%s status = %v (%v); want %v", res.Path, res.Status, res.Err, want[res.Path])
+		}
+	}
+	if int(progress.Load()) != len(want) {
+		t.Errorf("Progress called %d times; want %d", progress.Load(), len(want))
+	}
+	if report.OK() {
+		t.Error("OK() = true; want false")
+	}
+
+	// 输出保持目录结构，负载按文件替换模板
+	stego, _ := steganography.New("LSB")
+	for path, payload := range map[string]string{
+		"a.png":     "ID=a path=a.jpeg #1",
+		"sub/b.png": "ID=b path=sub/b.jpg #5",
+	} {
+		f, err := os.Open(filepath.Join(out, path))
+		if err != nil {
+			t.Fatalf("open output: %v", err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		data, err := steganography.Extract(stego, img, &steganography.Options{Key: "batch"})
+		if err != nil {
+			t.Fatalf("Extract(%s) error = %v", path, err)
+		}
+		if string(data) != payload {
+			t.Errorf("Extract(%s) = %q; want %q", path, data, payload)
+		}
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	in := t.TempDir()
+	writeFile(t, filepath.Join(in, "a.png"), newTestImage(32, 32), "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := Run(ctx, Config{InputDir: in, OutputDir: t.TempDir(), Algorithm: "LSB"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v; want context.Canceled", err)
+	}
+	if report == nil || len(report.Results) > 1 {
+		t.Errorf("Run() report = %+v", report)
+	}
+}
+
+func TestReport_WriteTo(t *testing.T) {
+	report := &Report{Results: []Result{
+		{Path: "a.png", Status: StatusOK},
+		{Path: "b.png", Status: StatusTooLarge, Err: steganography.ErrTooLarge},
+		{Path: "c.txt", Status: StatusSkipped},
+	}}
+
+	var sb strings.Builder
+	if _, err := report.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := "容量不足\tb.png\t数据太长，超出图像容量\n" +
+		"跳过\tc.txt\n" +
+		"共 3 个文件：成功 1，容量不足 1，跳过 1，失败 0\n"
+	if sb.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,419 @@
+package jpegcoef
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 标记
+const (
+	markerSOF0 = 0xc0
+	markerSOF1 = 0xc1
+	markerSOF2 = 0xc2
+	markerDHT  = 0xc4
+	markerRST0 = 0xd0
+	markerRST7 = 0xd7
+	markerSOI  = 0xd8
+	markerEOI  = 0xd9
+	markerSOS  = 0xda
+	markerDQT  = 0xdb
+	markerDRI  = 0xdd
+	markerAPP0 = 0xe0
+	markerAPPF = 0xef
+	markerCOM  = 0xfe
+)
+
+var errTruncated = errors.New("jpegcoef: 数据不完整")
+
+// bitReader 读取熵编码数据，处理0xFF填充字节。遇到标记时不再前进，
+// 之后读到的都是0，由调用者在扫描结束后处理标记
+type bitReader struct {
+	data   []byte
+	pos    int
+	acc    uint32
+	nbits  int
+	marker bool
+}
+
+func (br *bitReader) bit() uint32 {
+	if br.nbits == 0 {
+		br.acc, br.nbits = uint32(br.next()), 8
+	}
+	br.nbits--
+	return br.acc >> br.nbits & 1
+}
+
+func (br *bitReader) next() byte {
+	if br.marker || br.pos >= len(br.data) {
+		return 0
+	}
+	b := br.data[br.pos]
+	if b != 0xff {
+		br.pos++
+		return b
+	}
+	if br.pos+1 < len(br.data) && br.data[br.pos+1] == 0 {
+		br.pos += 2
+		return 0xff
+	}
+	br.marker = true
+	return 0
+}
+
+func (br *bitReader) receive(n int) int32 {
+	var v int32
+	for i := 0; i < n; i++ {
+		v = v<<1 | int32(br.bit())
+	}
+	return v
+}
+
+// 重启标记前丢弃剩余的填充位并跳过标记
+func (br *bitReader) restart() error {
+	br.nbits = 0
+	br.marker = false
+	if br.pos+1 >= len(br.data) || br.data[br.pos] != 0xff ||
+		br.data[br.pos+1] < markerRST0 || br.data[br.pos+1] > markerRST7 {
+		return errors.New("jpegcoef: 缺少重启标记")
+	}
+	br.pos += 2
+	return nil
+}
+
+// 将差值编码的s位附加位还原为有符号数（附录F.2.2.1的EXTEND）
+func extend(v int32, s int) int32 {
+	if s == 0 {
+		return 0
+	}
+	if v < 1<<(s-1) {
+		return v - (1 << s) + 1
+	}
+	return v
+}
+
+type decoder struct {
+	img      *Image
+	data     []byte
+	pos      int
+	dc, ac   [4]*huffDecoder
+	hasFrame bool
+	scanned  bool
+}
+
+// Decode 读取基线JPEG的量化系数
+func Decode(r io.Reader) (*Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{img: &Image{}, data: data}
+	if err := d.decode(); err != nil {
+		return nil, err
+	}
+	return d.img, nil
+}
+
+func (d *decoder) decode() error {
+	if len(d.data) < 2 || d.data[0] != 0xff || d.data[1] != markerSOI {
+		return errors.New("jpegcoef: 不是JPEG图像")
+	}
+	d.pos = 2
+
+	for {
+		// 标记前可以有任意个0xFF填充
+		if d.pos >= len(d.data) || d.data[d.pos] != 0xff {
+			return errTruncated
+		}
+		for d.pos < len(d.data) && d.data[d.pos] == 0xff {
+			d.pos++
+		}
+		if d.pos >= len(d.data) {
+			return errTruncated
+		}
+		marker := d.data[d.pos]
+		d.pos++
+
+		if marker == markerEOI {
+			break
+		}
+		if marker >= markerRST0 && marker <= markerRST7 {
+			continue
+		}
+		if d.pos+2 > len(d.data) {
+			return errTruncated
+		}
+		n := int(binary.BigEndian.Uint16(d.data[d.pos:]))
+		if n < 2 || d.pos+n > len(d.data) {
+			return errTruncated
+		}
+		start := d.pos - 2
+		body := d.data[d.pos+2 : d.pos+n]
+		d.pos += n
+
+		var err error
+		switch {
+		case marker == markerSOF0 || marker == markerSOF1:
+			err = d.parseSOF(body)
+		case marker >= markerSOF2 && marker <= 0xcf && marker != markerDHT && marker != 0xc8 && marker != 0xcc:
+			return fmt.Errorf("%w（SOF%d）", ErrUnsupported, marker-markerSOF0)
+		case marker == markerDHT:
+			err = d.parseDHT(body)
+		case marker == markerDQT:
+			err = d.parseDQT(body)
+		case marker == markerDRI:
+			if len(body) != 2 {
+				return errors.New("jpegcoef: 无效的DRI段")
+			}
+			d.img.RestartInterval = int(binary.BigEndian.Uint16(body))
+		case marker == markerSOS:
+			err = d.parseSOS(body)
+		case (marker >= markerAPP0 && marker <= markerAPPF) || marker == markerCOM:
+			d.img.segments = append(d.img.segments, d.data[start:d.pos])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !d.scanned {
+		return errors.New("jpegcoef: 没有图像数据")
+	}
+	return nil
+}
+
+func (d *decoder) parseSOF(body []byte) error {
+	if d.hasFrame {
+		return errors.New("jpegcoef: 重复的帧头")
+	}
+	if len(body) < 6 || body[0] != 8 {
+		return fmt.Errorf("%w（精度必须为8位）", ErrUnsupported)
+	}
+	img := d.img
+	img.Height = int(binary.BigEndian.Uint16(body[1:]))
+	img.Width = int(binary.BigEndian.Uint16(body[3:]))
+	n := int(body[5])
+	if img.Width == 0 || img.Height == 0 {
+		return fmt.Errorf("%w（图像尺寸为0）", ErrUnsupported)
+	}
+	if n != 1 && n != 3 && n != 4 || len(body) != 6+3*n {
+		return errors.New("jpegcoef: 无效的帧头")
+	}
+	for i := 0; i < n; i++ {
+		b := body[6+3*i:]
+		c := &Component{ID: b[0], H: int(b[1] >> 4), V: int(b[1] & 15), Tq: int(b[2])}
+		if c.H < 1 || c.H > 4 || c.V < 1 || c.V > 4 || c.Tq > 3 {
+			return errors.New("jpegcoef: 无效的分量参数")
+		}
+		img.Components = append(img.Components, c)
+	}
+	// 只有一个分量时MCU固定为一个块
+	if n == 1 {
+		img.Components[0].H, img.Components[0].V = 1, 1
+	}
+	img.allocate()
+	d.hasFrame = true
+	return nil
+}
+
+func (d *decoder) parseDQT(body []byte) error {
+	for len(body) > 0 {
+		pq, tq := body[0]>>4, body[0]&15
+		if tq > 3 || pq > 1 {
+			return errors.New("jpegcoef: 无效的量化表")
+		}
+		size := 64 * int(pq+1)
+		if len(body) < 1+size {
+			return errTruncated
+		}
+		table := new([64]uint16)
+		for k := 0; k < 64; k++ {
+			if pq == 0 {
+				table[Zigzag[k]] = uint16(body[1+k])
+			} else {
+				table[Zigzag[k]] = binary.BigEndian.Uint16(body[1+2*k:])
+			}
+		}
+		d.img.Quant[tq] = table
+		body = body[1+size:]
+	}
+	return nil
+}
+
+func (d *decoder) parseDHT(body []byte) error {
+	for len(body) > 0 {
+		if len(body) < 17 {
+			return errTruncated
+		}
+		class, id := body[0]>>4, body[0]&15
+		if class > 1 || id > 3 {
+			return errors.New("jpegcoef: 无效的Huffman表")
+		}
+		var spec huffSpec
+		total := 0
+		for i := 0; i < 16; i++ {
+			spec.counts[i] = body[1+i]
+			total += int(body[1+i])
+		}
+		if total > 256 || len(body) < 17+total {
+			return errors.New("jpegcoef: 无效的Huffman表")
+		}
+		spec.symbols = append([]uint8(nil), body[17:17+total]...)
+		if class == 0 {
+			d.dc[id] = newHuffDecoder(spec)
+		} else {
+			d.ac[id] = newHuffDecoder(spec)
+		}
+		body = body[17+total:]
+	}
+	return nil
+}
+
+// 扫描中的一个分量及其使用的Huffman表
+type scanComponent struct {
+	c      *Component
+	dc, ac *huffDecoder
+	pred   int32
+}
+
+func (d *decoder) parseSOS(body []byte) error {
+	if !d.hasFrame {
+		return errors.New("jpegcoef: 扫描出现在帧头之前")
+	}
+	if len(body) < 1 {
+		return errTruncated
+	}
+	n := int(body[0])
+	if n < 1 || n > 4 || len(body) != 4+2*n {
+		return errors.New("jpegcoef: 无效的扫描头")
+	}
+	if ss, se, a := body[1+2*n], body[2+2*n], body[3+2*n]; ss != 0 || se != 63 || a != 0 {
+		return fmt.Errorf("%w（频谱选择或逐次逼近）", ErrUnsupported)
+	}
+
+	scan := make([]*scanComponent, n)
+	for i := range scan {
+		id, tables := body[1+2*i], body[2+2*i]
+		sc := &scanComponent{}
+		for _, c := range d.img.Components {
+			if c.ID == id {
+				sc.c = c
+			}
+		}
+		td, ta := tables>>4, tables&15
+		if sc.c == nil || td > 3 || ta > 3 || d.dc[td] == nil || d.ac[ta] == nil {
+			return errors.New("jpegcoef: 扫描引用了未定义的分量或Huffman表")
+		}
+		sc.dc, sc.ac = d.dc[td], d.ac[ta]
+		scan[i] = sc
+	}
+
+	br := &bitReader{data: d.data, pos: d.pos}
+	if err := d.decodeScan(br, scan); err != nil {
+		return err
+	}
+	d.pos = br.pos
+	d.scanned = true
+	return nil
+}
+
+// 按扫描顺序解码，每个重启间隔结束后跳过重启标记并重置DC预测值
+func (d *decoder) decodeScan(br *bitReader, scan []*scanComponent) error {
+	comps := make([]*Component, len(scan))
+	for i, sc := range scan {
+		comps[i] = sc.c
+	}
+	return walkScan(d.img, comps, func(i int, b *Block) error {
+		return decodeBlock(br, scan[i], b)
+	}, func() error {
+		for _, sc := range scan {
+			sc.pred = 0
+		}
+		return br.restart()
+	})
+}
+
+// walkScan 按扫描顺序遍历各块，block的参数为分量在comps中的下标。
+// 只有一个分量时不交织，按该分量有效区域的块逐个遍历；多个分量时按MCU交织。
+// 每满RestartInterval个MCU（最后一个除外）调用一次restart
+func walkScan(img *Image, comps []*Component, block func(i int, b *Block) error, restart func() error) error {
+	n := 0
+	visit := func(mcu func() error) error {
+		if img.RestartInterval > 0 && n > 0 && n%img.RestartInterval == 0 {
+			if err := restart(); err != nil {
+				return err
+			}
+		}
+		n++
+		return mcu()
+	}
+
+	if len(comps) == 1 {
+		c := comps[0]
+		for by := 0; by < c.BlocksHigh; by++ {
+			for bx := 0; bx < c.BlocksWide; bx++ {
+				if err := visit(func() error { return block(0, c.Block(bx, by)) }); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	mcuX, mcuY := img.mcus()
+	for my := 0; my < mcuY; my++ {
+		for mx := 0; mx < mcuX; mx++ {
+			err := visit(func() error {
+				for i, c := range comps {
+					for v := 0; v < c.V; v++ {
+						for h := 0; h < c.H; h++ {
+							if err := block(i, c.Block(mx*c.H+h, my*c.V+v)); err != nil {
+								return err
+							}
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodeBlock(br *bitReader, sc *scanComponent, b *Block) error {
+	s, err := sc.dc.decode(br)
+	if err != nil {
+		return err
+	}
+	if s > 11 {
+		return errBadHuffmanCode
+	}
+	sc.pred += extend(br.receive(int(s)), int(s))
+	b[0] = sc.pred
+
+	for k := 1; k < 64; {
+		rs, err := sc.ac.decode(br)
+		if err != nil {
+			return err
+		}
+		r, s := int(rs>>4), int(rs&15)
+		if s == 0 {
+			if r != 15 {
+				break
+			}
+			k += 16
+			continue
+		}
+		k += r
+		if k > 63 {
+			return errors.New("jpegcoef: 系数下标越界")
+		}
+		b[k] = extend(br.receive(s), s)
+		k++
+	}
+	return nil
+}
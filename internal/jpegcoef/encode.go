@@ -0,0 +1,259 @@
+package jpegcoef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// bitWriter 写入熵编码数据，在0xFF后填充0x00
+type bitWriter struct {
+	buf   *bytes.Buffer
+	acc   uint32
+	nbits int
+}
+
+func (bw *bitWriter) write(v uint32, n int) {
+	bw.acc = bw.acc<<n | v&(1<<n-1)
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		b := byte(bw.acc >> (bw.nbits - 8))
+		bw.buf.WriteByte(b)
+		if b == 0xff {
+			bw.buf.WriteByte(0)
+		}
+		bw.nbits -= 8
+	}
+	bw.acc &= 1<<bw.nbits - 1
+}
+
+// 用1填充到字节边界
+func (bw *bitWriter) flush() {
+	if bw.nbits > 0 {
+		bw.write(1<<(8-bw.nbits)-1, 8-bw.nbits)
+	}
+}
+
+// 符号及其附加位的输出方式，统计频率和实际写入共用同一套块编码逻辑
+type emitFunc func(ac bool, symbol uint8, extra uint32, n int) error
+
+// 有符号数的类别（位数）和附加位
+func category(v int32) (int, uint32) {
+	if v < 0 {
+		n := bits.Len32(uint32(-v))
+		return n, uint32(v + 1<<n - 1)
+	}
+	return bits.Len32(uint32(v)), uint32(v)
+}
+
+func encodeBlock(b *Block, pred *int32, emit emitFunc) error {
+	n, extra := category(b[0] - *pred)
+	if n > 11 {
+		return errors.New("jpegcoef: DC系数超出范围")
+	}
+	*pred = b[0]
+	if err := emit(false, uint8(n), extra, n); err != nil {
+		return err
+	}
+
+	run := 0
+	for k := 1; k < 64; k++ {
+		if b[k] == 0 {
+			run++
+			continue
+		}
+		for ; run >= 16; run -= 16 {
+			if err := emit(true, 0xf0, 0, 0); err != nil {
+				return err
+			}
+		}
+		n, extra := category(b[k])
+		if n > 10 {
+			return errors.New("jpegcoef: AC系数超出范围")
+		}
+		if err := emit(true, uint8(run<<4|n), extra, n); err != nil {
+			return err
+		}
+		run = 0
+	}
+	if run > 0 {
+		return emit(true, 0x00, 0, 0)
+	}
+	return nil
+}
+
+// 亮度（第一个分量）使用0号Huffman表，其余分量使用1号表
+func tableIndex(i int) int {
+	return min(i, 1)
+}
+
+// 按扫描顺序编码全部块，restart在每个重启间隔结束时调用
+func (img *Image) encodeScan(emit func(i int) emitFunc, restart func() error) error {
+	preds := make([]int32, len(img.Components))
+	return walkScan(img, img.Components, func(i int, b *Block) error {
+		return encodeBlock(b, &preds[i], emit(i))
+	}, func() error {
+		clear(preds)
+		return restart()
+	})
+}
+
+// Encode 将系数重新编码为基线JPEG。Huffman表根据系数统计重新生成，
+// 量化表、重启间隔和原图的APPn、COM段保持不变
+func (img *Image) Encode(w io.Writer) error {
+	if len(img.Components) == 0 {
+		return errors.New("jpegcoef: 没有颜色分量")
+	}
+	blocks := 0
+	for _, c := range img.Components {
+		if img.Quant[c.Tq] == nil {
+			return fmt.Errorf("jpegcoef: 分量%d的量化表未定义", c.ID)
+		}
+		blocks += c.H * c.V
+	}
+	if len(img.Components) > 1 && blocks > 10 {
+		return fmt.Errorf("%w（每个MCU超过10个块）", ErrUnsupported)
+	}
+
+	// 第一遍统计符号频率
+	var dcFreq, acFreq [2][256]int
+	err := img.encodeScan(func(i int) emitFunc {
+		t := tableIndex(i)
+		return func(ac bool, symbol uint8, _ uint32, _ int) error {
+			if ac {
+				acFreq[t][symbol]++
+			} else {
+				dcFreq[t][symbol]++
+			}
+			return nil
+		}
+	}, func() error { return nil })
+	if err != nil {
+		return err
+	}
+	tables := min(len(img.Components), 2)
+	var dcSpec, acSpec [2]huffSpec
+	var dcEnc, acEnc [2]*huffEncoder
+	for t := 0; t < tables; t++ {
+		dcSpec[t], acSpec[t] = optimalSpec(dcFreq[t]), optimalSpec(acFreq[t])
+		dcEnc[t], acEnc[t] = newHuffEncoder(dcSpec[t]), newHuffEncoder(acSpec[t])
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{0xff, markerSOI})
+	for _, s := range img.segments {
+		buf.Write(s)
+	}
+	extended := img.writeDQT(buf)
+	img.writeSOF(buf, extended)
+	for t := 0; t < tables; t++ {
+		writeDHT(buf, 0, t, dcSpec[t])
+		writeDHT(buf, 1, t, acSpec[t])
+	}
+	if img.RestartInterval > 0 {
+		writeSegment(buf, markerDRI, binary.BigEndian.AppendUint16(nil, uint16(img.RestartInterval)))
+	}
+	img.writeSOS(buf)
+
+	// 第二遍写入熵编码数据
+	bw := &bitWriter{buf: buf}
+	rst := 0
+	err = img.encodeScan(func(i int) emitFunc {
+		t := tableIndex(i)
+		return func(ac bool, symbol uint8, extra uint32, n int) error {
+			enc := dcEnc[t]
+			if ac {
+				enc = acEnc[t]
+			}
+			if err := enc.emit(bw, symbol); err != nil {
+				return err
+			}
+			bw.write(extra, n)
+			return nil
+		}
+	}, func() error {
+		bw.flush()
+		buf.Write([]byte{0xff, byte(markerRST0 + rst%8)})
+		rst++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bw.flush()
+	buf.Write([]byte{0xff, markerEOI})
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func writeSegment(buf *bytes.Buffer, marker byte, body []byte) {
+	buf.Write([]byte{0xff, marker})
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(body)+2)))
+	buf.Write(body)
+}
+
+// 写入量化表，有超过255的值时使用16位精度并返回true
+func (img *Image) writeDQT(buf *bytes.Buffer) bool {
+	extended := false
+	for tq, table := range img.Quant {
+		if table == nil {
+			continue
+		}
+		wide := false
+		for _, q := range table {
+			wide = wide || q > 255
+		}
+		extended = extended || wide
+
+		var body []byte
+		if wide {
+			body = append(body, 1<<4|byte(tq))
+			for k := 0; k < 64; k++ {
+				body = binary.BigEndian.AppendUint16(body, table[Zigzag[k]])
+			}
+		} else {
+			body = append(body, byte(tq))
+			for k := 0; k < 64; k++ {
+				body = append(body, byte(table[Zigzag[k]]))
+			}
+		}
+		writeSegment(buf, markerDQT, body)
+	}
+	return extended
+}
+
+func (img *Image) writeSOF(buf *bytes.Buffer, extended bool) {
+	body := []byte{8}
+	body = binary.BigEndian.AppendUint16(body, uint16(img.Height))
+	body = binary.BigEndian.AppendUint16(body, uint16(img.Width))
+	body = append(body, byte(len(img.Components)))
+	for _, c := range img.Components {
+		body = append(body, c.ID, byte(c.H<<4|c.V), byte(c.Tq))
+	}
+	marker := byte(markerSOF0)
+	if extended {
+		marker = markerSOF1
+	}
+	writeSegment(buf, marker, body)
+}
+
+func writeDHT(buf *bytes.Buffer, class, id int, spec huffSpec) {
+	body := []byte{byte(class<<4 | id)}
+	body = append(body, spec.counts[:]...)
+	body = append(body, spec.symbols...)
+	writeSegment(buf, markerDHT, body)
+}
+
+func (img *Image) writeSOS(buf *bytes.Buffer) {
+	body := []byte{byte(len(img.Components))}
+	for i, c := range img.Components {
+		t := byte(tableIndex(i))
+		body = append(body, c.ID, t<<4|t)
+	}
+	body = append(body, 0, 63, 0)
+	writeSegment(buf, markerSOS, body)
+}
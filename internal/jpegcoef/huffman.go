@@ -0,0 +1,174 @@
+package jpegcoef
+
+import (
+	"errors"
+	"fmt"
+)
+
+// huffSpec 是DHT段中的Huffman表：各码长的符号数和按码长排列的符号
+type huffSpec struct {
+	counts  [16]uint8
+	symbols []uint8
+}
+
+// huffDecoder 按JPEG附录F.2.2.3的方法逐位解码
+type huffDecoder struct {
+	maxCode [17]int32
+	valPtr  [17]int32
+	minCode [17]int32
+	symbols []uint8
+}
+
+func newHuffDecoder(spec huffSpec) *huffDecoder {
+	d := &huffDecoder{symbols: spec.symbols}
+	code, k := int32(0), int32(0)
+	for l := 1; l <= 16; l++ {
+		n := int32(spec.counts[l-1])
+		if n == 0 {
+			d.maxCode[l] = -1
+		} else {
+			d.valPtr[l] = k
+			d.minCode[l] = code
+			code += n
+			k += n
+			d.maxCode[l] = code - 1
+		}
+		code <<= 1
+	}
+	return d
+}
+
+var errBadHuffmanCode = errors.New("jpegcoef: 无效的Huffman编码")
+
+func (d *huffDecoder) decode(br *bitReader) (uint8, error) {
+	code := int32(0)
+	for l := 1; l <= 16; l++ {
+		code = code<<1 | int32(br.bit())
+		if code <= d.maxCode[l] {
+			return d.symbols[d.valPtr[l]+code-d.minCode[l]], nil
+		}
+	}
+	return 0, errBadHuffmanCode
+}
+
+// huffEncoder 保存每个符号的码字和码长
+type huffEncoder struct {
+	code [256]uint16
+	size [256]uint8
+}
+
+func newHuffEncoder(spec huffSpec) *huffEncoder {
+	e := &huffEncoder{}
+	code, k := uint16(0), 0
+	for l := 1; l <= 16; l++ {
+		for i := 0; i < int(spec.counts[l-1]); i++ {
+			s := spec.symbols[k]
+			e.code[s], e.size[s] = code, uint8(l)
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return e
+}
+
+func (e *huffEncoder) emit(bw *bitWriter, symbol uint8) error {
+	if e.size[symbol] == 0 {
+		return fmt.Errorf("jpegcoef: Huffman表中没有符号%#x", symbol)
+	}
+	bw.write(uint32(e.code[symbol]), int(e.size[symbol]))
+	return nil
+}
+
+// optimalSpec 按JPEG附录K.2的方法根据符号频率生成码长不超过16的Huffman表。
+// 保留一个频率为1的虚拟符号，使任何码字都不会是全1
+func optimalSpec(freq [256]int) huffSpec {
+	var f [257]int
+	copy(f[:], freq[:])
+	f[256] = 1
+	// 至少需要一个真实符号，否则无法去掉虚拟符号
+	used := false
+	for _, n := range freq {
+		used = used || n > 0
+	}
+	if !used {
+		f[0] = 1
+	}
+
+	var codeSize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+	for {
+		// 频率最小的两个符号，频率相同时取符号值较大的
+		v1, v2 := -1, -1
+		for i := range f {
+			if f[i] > 0 && (v1 < 0 || f[i] <= f[v1]) {
+				v1 = i
+			}
+		}
+		for i := range f {
+			if f[i] > 0 && i != v1 && (v2 < 0 || f[i] <= f[v2]) {
+				v2 = i
+			}
+		}
+		if v2 < 0 {
+			break
+		}
+
+		f[v1] += f[v2]
+		f[v2] = 0
+		codeSize[v1]++
+		for others[v1] >= 0 {
+			v1 = others[v1]
+			codeSize[v1]++
+		}
+		others[v1] = v2
+		codeSize[v2]++
+		for others[v2] >= 0 {
+			v2 = others[v2]
+			codeSize[v2]++
+		}
+	}
+
+	// 257个符号的码长不会超过256
+	var bits [257]int
+	for _, size := range codeSize {
+		if size > 0 {
+			bits[size]++
+		}
+	}
+	// 将超过16位的码长调整到16位以内
+	for i := len(bits) - 1; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+	// 去掉虚拟符号，它总是最长的码字之一
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+	bits[i]--
+
+	var spec huffSpec
+	for l := 1; l <= 16; l++ {
+		spec.counts[l-1] = uint8(bits[l])
+	}
+	for size := 1; size < len(bits); size++ {
+		for s := 0; s < 256; s++ {
+			if codeSize[s] == size {
+				spec.symbols = append(spec.symbols, uint8(s))
+			}
+		}
+	}
+	return spec
+}
@@ -0,0 +1,89 @@
+// Package jpegcoef 在量化DCT系数层面读写基线JPEG。
+//
+// Decode 解码Huffman编码得到每个8x8块的量化系数，不做反量化和逆DCT；
+// Encode 将系数按原量化表重新进行熵编码。系数不变时重新编码是无损的，
+// 解码出的像素与原图完全相同。只支持基线和扩展的顺序Huffman编码
+// （SOF0/SOF1），不支持渐进式和算术编码
+package jpegcoef
+
+import "errors"
+
+// ErrUnsupported 表示图像使用了不支持的JPEG编码方式
+var ErrUnsupported = errors.New("jpegcoef: 不支持的JPEG编码方式")
+
+// Zigzag 将之字形顺序的下标转换为自然顺序（行优先）的下标
+var Zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// Block 是一个8x8块的量化系数，按之字形顺序存储，下标0为DC系数
+type Block [64]int32
+
+// Component 是一个颜色分量的全部系数
+type Component struct {
+	// ID 帧头中的分量标识
+	ID uint8
+	// H, V 水平和垂直采样因子
+	H, V int
+	// Tq 使用的量化表编号
+	Tq int
+	// BlocksWide, BlocksHigh 覆盖分量有效区域所需的块数
+	BlocksWide, BlocksHigh int
+
+	// 块按MCU对齐后的行宽，填充出的块同样参与交织扫描的编码
+	stride int
+	blocks []Block
+}
+
+// Block 返回第by行第bx列的块，坐标可以超出有效区域但不能超出MCU对齐后的范围
+func (c *Component) Block(bx, by int) *Block {
+	return &c.blocks[by*c.stride+bx]
+}
+
+// Image 是解码后的JPEG系数数据
+type Image struct {
+	// Width, Height 图像尺寸
+	Width, Height int
+	// Components 颜色分量，灰度图只有一个
+	Components []*Component
+	// Quant 量化表，按自然顺序存储，未定义的为nil
+	Quant [4]*[64]uint16
+	// RestartInterval 重新编码时每隔多少个MCU插入一个重启标记，0表示不插入
+	RestartInterval int
+
+	// 原图中的APPn和COM段（含标记），重新编码时原样保留
+	segments   [][]byte
+	maxH, maxV int
+}
+
+// 每行、每列的MCU数
+func (img *Image) mcus() (int, int) {
+	return ceilDiv(img.Width, 8*img.maxH), ceilDiv(img.Height, 8*img.maxV)
+}
+
+// 分配各分量的系数空间
+func (img *Image) allocate() {
+	img.maxH, img.maxV = 1, 1
+	for _, c := range img.Components {
+		img.maxH = max(img.maxH, c.H)
+		img.maxV = max(img.maxV, c.V)
+	}
+	mcuX, mcuY := img.mcus()
+	for _, c := range img.Components {
+		c.BlocksWide = ceilDiv(ceilDiv(img.Width*c.H, img.maxH), 8)
+		c.BlocksHigh = ceilDiv(ceilDiv(img.Height*c.V, img.maxV), 8)
+		c.stride = mcuX * c.H
+		c.blocks = make([]Block, c.stride*mcuY*c.V)
+	}
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
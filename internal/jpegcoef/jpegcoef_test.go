@@ -0,0 +1,213 @@
+package jpegcoef
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand/v2"
+	"testing"
+)
+
+// 带噪声的渐变图，尺寸不是8的倍数以覆盖边缘的填充块
+func newTestImage(gray bool) image.Image {
+	r := rand.New(rand.NewPCG(1, 2))
+	bounds := image.Rect(0, 0, 101, 77)
+	if gray {
+		img := image.NewGray(bounds)
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				img.SetGray(x, y, color.Gray{Y: uint8(x*2 + r.IntN(40))})
+			}
+		}
+		return img
+	}
+	img := image.NewRGBA(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			img.Set(x, y, color.RGBA{uint8(x*2 + r.IntN(40)), uint8(y*3 + r.IntN(40)), uint8(x + y), 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("jpeg.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func reencode(t *testing.T, img *Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := img.Encode(&buf); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func samePixels(t *testing.T, a, b []byte) {
+	t.Helper()
+	ia, err := jpeg.Decode(bytes.NewReader(a))
+	if err != nil {
+		t.Fatalf("jpeg.Decode() error = %v", err)
+	}
+	ib, err := jpeg.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("jpeg.Decode() re-encoded error = %v", err)
+	}
+	bounds := ia.Bounds()
+	if ib.Bounds() != bounds {
+		t.Fatalf("bounds = %v; want %v", ib.Bounds(), bounds)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if ia.At(x, y) != ib.At(x, y) {
+				t.Fatalf("pixel (%d, %d) = %v; want %v", x, y, ib.At(x, y), ia.At(x, y))
+			}
+		}
+	}
+}
+
+func sameCoefficients(t *testing.T, a, b *Image) {
+	t.Helper()
+	if len(a.Components) != len(b.Components) {
+		t.Fatalf("components = %d; want %d", len(b.Components), len(a.Components))
+	}
+	for i, c := range a.Components {
+		for by := 0; by < c.BlocksHigh; by++ {
+			for bx := 0; bx < c.BlocksWide; bx++ {
+				if *c.Block(bx, by) != *b.Components[i].Block(bx, by) {
+					t.Fatalf("component %d block (%d, %d) differs", i, bx, by)
+				}
+			}
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		gray    bool
+		quality int
+	}{
+		{"color q75", false, 75},
+		{"color q100", false, 100},
+		{"gray q50", true, 50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := encodeJPEG(t, newTestImage(tc.gray), tc.quality)
+			img, err := Decode(bytes.NewReader(orig))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if img.Width != 101 || img.Height != 77 {
+				t.Errorf("size = %dx%d; want 101x77", img.Width, img.Height)
+			}
+
+			out := reencode(t, img)
+			samePixels(t, orig, out)
+			again, err := Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("Decode() re-encoded error = %v", err)
+			}
+			sameCoefficients(t, img, again)
+
+			// 优化后的Huffman表不应使文件变大
+			if len(out) > len(orig) {
+				t.Errorf("re-encoded size = %d; original %d", len(out), len(orig))
+			}
+		})
+	}
+}
+
+func TestRestartInterval(t *testing.T) {
+	orig := encodeJPEG(t, newTestImage(false), 80)
+	img, err := Decode(bytes.NewReader(orig))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	// 带重启标记重新编码，再解码一次确认读取重启标记的逻辑
+	for _, interval := range []int{1, 3, 7} {
+		img.RestartInterval = interval
+		out := reencode(t, img)
+		samePixels(t, orig, out)
+		again, err := Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("interval %d: Decode() error = %v", interval, err)
+		}
+		if again.RestartInterval != interval {
+			t.Errorf("RestartInterval = %d; want %d", again.RestartInterval, interval)
+		}
+		sameCoefficients(t, img, again)
+	}
+}
+
+func TestModifiedCoefficients(t *testing.T) {
+	orig := encodeJPEG(t, newTestImage(false), 75)
+	img, err := Decode(bytes.NewReader(orig))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	// 修改后的系数应在重新编码后原样读出
+	c := img.Components[0]
+	c.Block(0, 0)[5] += 3
+	c.Block(1, 2)[63] = -7
+	img.Components[2].Block(3, 1)[1] = 0
+
+	again, err := Decode(bytes.NewReader(reencode(t, img)))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	sameCoefficients(t, img, again)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	orig := encodeJPEG(t, newTestImage(false), 75)
+
+	if _, err := Decode(bytes.NewReader([]byte("not a jpeg"))); err == nil {
+		t.Error("Decode() of non-JPEG data succeeded")
+	}
+	if _, err := Decode(bytes.NewReader(orig[:len(orig)/2])); err == nil {
+		t.Error("Decode() of truncated data succeeded")
+	}
+
+	// 将SOF0改为SOF2（渐进式）
+	progressive := bytes.Replace(orig, []byte{0xff, markerSOF0}, []byte{0xff, markerSOF2}, 1)
+	if _, err := Decode(bytes.NewReader(progressive)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Decode() of progressive JPEG error = %v; want ErrUnsupported", err)
+	}
+}
+
+func TestOptimalSpec(t *testing.T) {
+	// 极度倾斜的频率分布会产生超过16位的码长，需要被截断
+	var freq [256]int
+	for i := 0; i < 40; i++ {
+		freq[i] = 1 << min(i, 30)
+	}
+	spec := optimalSpec(freq)
+	total := 0
+	for _, n := range spec.counts {
+		total += int(n)
+	}
+	if total != 40 || len(spec.symbols) != 40 {
+		t.Fatalf("symbols = %d (counts sum %d); want 40", len(spec.symbols), total)
+	}
+
+	// 码字必须满足前缀条件且没有全1码字
+	enc := newHuffEncoder(spec)
+	for _, s := range spec.symbols {
+		size := enc.size[s]
+		if size == 0 || size > 16 {
+			t.Fatalf("symbol %d size = %d", s, size)
+		}
+		if enc.code[s] == 1<<size-1 {
+			t.Errorf("symbol %d has an all-ones code", s)
+		}
+	}
+}
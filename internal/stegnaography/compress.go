@@ -0,0 +1,45 @@
+package steganography
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// 解压后的数据上限，防止构造的负载解压出超大数据
+const maxDecompressedSize = 256 << 20
+
+// 使用DEFLATE压缩数据，压缩后不比原数据小时返回false
+func compress(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return data, false
+	}
+	if _, err := w.Write(data); err != nil {
+		return data, false
+	}
+	if err := w.Close(); err != nil {
+		return data, false
+	}
+	if buf.Len() >= len(data) {
+		return data, false
+	}
+	return buf.Bytes(), true
+}
+
+// 解压由compress压缩的数据
+func decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解压失败: %v", ErrCorrupted, err)
+	}
+	if len(out) > maxDecompressedSize {
+		return nil, fmt.Errorf("%w: 解压后的数据超过 %d 字节", ErrCorrupted, maxDecompressedSize)
+	}
+	return out, nil
+}
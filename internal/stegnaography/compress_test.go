@@ -0,0 +1,84 @@
+package steganography
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEmbed_Compress(t *testing.T) {
+	text := []byte(strings.Repeat("重复的文本可以被压缩。", 40))
+	dct := NewDCTSteganography()
+	img := newGradientImage(256)
+
+	// 不压缩时超出DCT容量，压缩后可以嵌入
+	if len(text) <= dct.Capacity(img.Bounds()) {
+		t.Fatalf("test text too short: %d bytes", len(text))
+	}
+	opts := &Options{Compress: true, Passphrase: "secret"}
+	if size := PayloadSize(text, opts); size > dct.Capacity(img.Bounds()) {
+		t.Fatalf("PayloadSize() = %d; exceeds capacity %d", size, dct.Capacity(img.Bounds()))
+	}
+
+	encodedImg, err := Embed(dct, img, text, opts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	_, header, err := Inspect(encodedImg, nil)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if header.Flags&FlagCompressed == 0 {
+		t.Errorf("header flags = %#x; want FlagCompressed set", header.Flags)
+	}
+	if int(header.Length) != PayloadSize(text, opts) {
+		t.Errorf("header length = %d; want PayloadSize() = %d", header.Length, PayloadSize(text, opts))
+	}
+	// 缓存压缩后的大小时，再计入加密和纠错的开销
+	compressed := PayloadSize(text, &Options{Compress: true})
+	for _, o := range []*Options{opts, {Compress: true, ECC: ECCMedium}} {
+		if got, want := EncodedSize(compressed, o), PayloadSize(text, o); got != want {
+			t.Errorf("EncodedSize(%d, %+v) = %d; want PayloadSize() = %d", compressed, o, got, want)
+		}
+	}
+
+	extracted, err := Extract(dct, encodedImg, &Options{Passphrase: "secret"})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !bytes.Equal(extracted, text) {
+		t.Errorf("Extract() returned %d bytes; want %d", len(extracted), len(text))
+	}
+}
+
+func TestCompress_Incompressible(t *testing.T) {
+	data := make([]byte, 256)
+	rand.Read(data)
+
+	if out, ok := compress(data); ok || !bytes.Equal(out, data) {
+		t.Errorf("compress() of random data ok = %v; want false and unchanged data", ok)
+	}
+	if size := PayloadSize(data, &Options{Compress: true}); size != len(data) {
+		t.Errorf("PayloadSize() = %d; want %d", size, len(data))
+	}
+
+	encodedImg, err := Embed(NewLSB(), newGradientImage(64), data, &Options{Compress: true})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	_, header, err := Inspect(encodedImg, nil)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if header.Flags&FlagCompressed != 0 {
+		t.Errorf("header flags = %#x; want FlagCompressed unset", header.Flags)
+	}
+}
+
+func TestDecompress_Invalid(t *testing.T) {
+	if _, err := decompress([]byte{0xff, 0xff, 0xff}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("decompress() error = %v; want ErrCorrupted", err)
+	}
+}
@@ -0,0 +1,64 @@
+package steganography
+
+import (
+	"fmt"
+	"image"
+)
+
+// softCarrier 是能给出每个比特判决置信度的载体
+type softCarrier interface {
+	// extractSoft 按与extractBits相同的顺序读取前n个比特及各自的置信度
+	extractSoft(img image.Image, n int, l layout) ([]int, []float64, error)
+}
+
+// ExtractWithConfidence 与ExtractFile相同，同时返回帧中每个比特的判决置信度。
+// 置信度按嵌入顺序排列，覆盖负载头、负载和纠错校验，取值0到1：系数位于格点上时
+// 为1，越接近判决边界越小，说明该比特越可能因噪声或有损处理而出错。
+// 目前只有DWT支持
+func ExtractWithConfidence(s Steganographer, img image.Image, opts *Options) (*File, []float64, error) {
+	c, err := carrierOf(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := c.(softCarrier); !ok {
+		return nil, nil, fmt.Errorf("算法%s不支持软判决提取", s.Name())
+	}
+
+	padded, l := extractLayout(s, img, opts)
+	data, header, err := extractPayload(c, padded, opts, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &File{Data: data}
+	if header.Flags&FlagFile != 0 {
+		if f, err = parseFile(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	f.Corrected = header.Corrected
+
+	// 数据可能是按其他参数组合找到的，用与负载头一致的载体读取置信度
+	soft, ok := matchingCarrier(c, header).(softCarrier)
+	if !ok {
+		return nil, nil, fmt.Errorf("算法%s不支持软判决提取", s.Name())
+	}
+	_, confidence, err := soft.extractSoft(padded, header.frameSize()*8, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, confidence, nil
+}
+
+// 返回参数与负载头一致的载体，优先使用c本身
+func matchingCarrier(c carrier, header *Header) carrier {
+	candidates := []carrier{c}
+	if v, ok := c.(variantCarrier); ok {
+		candidates = append(candidates, v.variants()...)
+	}
+	for _, candidate := range candidates {
+		if adapted := adaptCarrier(candidate, header); adapted.params() == header.Params {
+			return adapted
+		}
+	}
+	return c
+}
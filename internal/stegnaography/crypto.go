@@ -0,0 +1,94 @@
+package steganography
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt参数，修改后需提升负载头版本
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	saltSize     = 16
+	keySize      = 32
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+// 口令加密额外占用的字节数：盐 + 随机数 + 认证标签
+const passphraseOverhead = saltSize + gcmNonceSize + gcmTagSize
+
+var (
+	// ErrPassphraseRequired 表示数据已加密，但没有提供口令
+	ErrPassphraseRequired = errors.New("数据已加密，需要口令")
+	// ErrWrongPassphrase 表示口令错误（或密文被篡改）
+	ErrWrongPassphrase = errors.New("口令错误")
+)
+
+// 使用scrypt派生密钥，并以AES-256-GCM加密，输出格式为 盐|随机数|密文
+func sealWithPassphrase(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成盐失败: %v", err)
+	}
+
+	aead, err := passphraseAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %v", err)
+	}
+
+	sealed := make([]byte, 0, passphraseOverhead+len(plaintext))
+	sealed = append(sealed, salt...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, plaintext, nil), nil
+}
+
+// 解密由sealWithPassphrase生成的数据
+func openWithPassphrase(sealed []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	if len(sealed) < passphraseOverhead {
+		return nil, fmt.Errorf("%w: 密文太短", ErrCorrupted)
+	}
+
+	salt := sealed[:saltSize]
+	nonce := sealed[saltSize : saltSize+gcmNonceSize]
+	ciphertext := sealed[saltSize+gcmNonceSize:]
+
+	aead, err := passphraseAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		// 负载的CRC已经校验通过，认证失败只可能是口令错误
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+func passphraseAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("派生密钥失败: %v", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,68 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEmbed_Passphrase(t *testing.T) {
+	lsb := NewLSB()
+	secret := []byte("机密信息 top secret \x00 with zero byte")
+	opts := &Options{Passphrase: "correct horse battery staple"}
+
+	encodedImg, err := Embed(lsb, newGradientImage(128), secret, opts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	t.Run("正确口令", func(t *testing.T) {
+		extracted, err := Extract(lsb, encodedImg, opts)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if !bytes.Equal(extracted, secret) {
+			t.Errorf("Data mismatch:\nwant: %q\ngot:  %q", secret, extracted)
+		}
+	})
+
+	t.Run("错误口令", func(t *testing.T) {
+		_, err := Extract(lsb, encodedImg, &Options{Passphrase: "wrong"})
+		if !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("Extract() error = %v; want ErrWrongPassphrase", err)
+		}
+	})
+
+	t.Run("未提供口令", func(t *testing.T) {
+		_, err := lsb.ExtractBytes(encodedImg)
+		if !errors.Is(err, ErrPassphraseRequired) {
+			t.Errorf("ExtractBytes() error = %v; want ErrPassphraseRequired", err)
+		}
+	})
+
+	t.Run("明文不可见", func(t *testing.T) {
+		c, _ := carrierOf(lsb)
+		bits, err := c.extractBits(encodedImg, (headerSize+PayloadSize(secret, opts))*8, layout{region: encodedImg.Bounds()})
+		if err != nil {
+			t.Fatalf("extractBits() error = %v", err)
+		}
+		if bytes.Contains(bitsToBytes(bits), []byte("top secret")) {
+			t.Error("embedded payload contains plaintext")
+		}
+	})
+}
+
+func TestSealWithPassphrase_Tampered(t *testing.T) {
+	sealed, err := sealWithPassphrase([]byte("hello"), "pass")
+	if err != nil {
+		t.Fatalf("sealWithPassphrase() error = %v", err)
+	}
+	if len(sealed) != PayloadSize([]byte("hello"), &Options{Passphrase: "pass"}) {
+		t.Errorf("sealed size = %d; want %d", len(sealed), PayloadSize([]byte("hello"), &Options{Passphrase: "pass"}))
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := openWithPassphrase(sealed, "pass"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("openWithPassphrase() error = %v; want ErrWrongPassphrase", err)
+	}
+}
@@ -3,12 +3,84 @@ package steganography
 import (
 	"fmt"
 	"image"
-	"image/color"
 	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JPEG模式支持的目标质量。提取时需要枚举这些质量读取负载头，因此只允许有限的取值
+var jpegQualities = []int{50, 55, 60, 65, 70, 75, 80, 85, 90}
+
+// DCTCoefficient 是8×8块中一个DCT系数的位置，Row为垂直频率，Col为水平频率
+type DCTCoefficient struct {
+	Row, Col int
+}
+
+func (c DCTCoefficient) String() string {
+	return fmt.Sprintf("%d:%d", c.Row, c.Col)
+}
+
+// 可用于嵌入的中频系数，按优先顺序排列。参数中以位掩码记录使用了哪些，
+// 同一块中的多个比特也按此顺序存放。早期版本只使用第一个
+var dctCoefficients = [...]DCTCoefficient{{4, 3}, {3, 4}, {5, 2}, {2, 5}, {3, 3}, {4, 2}, {2, 4}, {4, 4}}
+
+// DCTCoefficients 返回可用于嵌入的中频系数，按优先顺序排列
+func DCTCoefficients() []DCTCoefficient {
+	return slices.Clone(dctCoefficients[:])
+}
+
+// ParseDCTCoefficients 解析以逗号分隔的系数位置，如"4:3,3:4"
+func ParseDCTCoefficients(spec string) ([]DCTCoefficient, error) {
+	var coefs []DCTCoefficient
+	for _, field := range strings.Split(spec, ",") {
+		var c DCTCoefficient
+		if _, err := fmt.Sscanf(strings.TrimSpace(field), "%d:%d", &c.Row, &c.Col); err != nil {
+			return nil, fmt.Errorf("无效的DCT系数位置 %q，格式为 行:列", field)
+		}
+		coefs = append(coefs, c)
+	}
+	return coefs, nil
+}
+
+// 符号嵌入支持的强度。提取时需要枚举这些强度读取负载头，因此只允许有限的取值
+var dctStrengths = []int{10, 15, 20, 25, 30, 40, 50}
+
+// 符号嵌入的默认强度
+const dctDefaultStrength = 25
+
+const (
+	// QIM步长与目标质量下JPEG量化步长的比值
+	jpegStepScale = 2
+	// JPEG模式嵌入后校验修正的最多轮数
+	maxCorrectionPasses = 4
 )
 
 type DCTSteganography struct {
+	// JPEGQuality 非0时按该质量的JPEG亮度量化表做QIM嵌入，输出图像以不低于
+	// 该质量重新保存为JPEG（image/jpeg）后仍可提取，取值为50–90之间5的倍数。
+	// 为0时使用符号嵌入，只适合保存为无损格式
+	JPEGQuality int
+
+	// Coefficients 为嵌入使用的系数，须取自DCTCoefficients()，每块在每个系数中
+	// 存储1位。为空时按优先顺序使用前BitsPerBlock个
+	Coefficients []DCTCoefficient
+
+	// BitsPerBlock 为每块存储的比特数（1–8），0视为1。与Coefficients同时指定时
+	// 须与其长度一致。比特数越多容量越大，对图像的改动也越大
+	BitsPerBlock int
+
+	// Strength 为符号嵌入时系数被推离0的距离，取值为dctStrengths之一，0视为25。
+	// 强度越大越能经受噪声，PSNR越低。JPEG模式的改动量由目标质量决定，不能指定
+	Strength int
+
 	blockSize int
+	// sequential 为true时按光栅顺序使用各块，用于读取交织之前嵌入的图像
+	sequential bool
+
+	// cache 由variants()创建的各组合共享，缓存同一张图像各块的DCT系数
+	cache *dctCache
 }
 
 func NewDCTSteganography() *DCTSteganography {
@@ -17,207 +89,505 @@ func NewDCTSteganography() *DCTSteganography {
 	}
 }
 
+// NewDCTWithJPEGQuality 创建能经受指定质量以上JPEG重新编码的DCT
+func NewDCTWithJPEGQuality(quality int) *DCTSteganography {
+	d := NewDCTSteganography()
+	d.JPEGQuality = quality
+	return d
+}
+
+// Name 返回算法名称
+func (d *DCTSteganography) Name() string {
+	return "DCT"
+}
+
+// Constraints DCT要求图像尺寸是块大小的倍数
+func (d *DCTSteganography) Constraints() Constraints {
+	return Constraints{BlockSize: d.blockSize}
+}
+
+// Capacity 每个块存储BitsPerBlock位，需扣除负载头
+func (d *DCTSteganography) Capacity(bounds image.Rectangle) int {
+	return payloadCapacity(d, bounds)
+}
+
 func (d *DCTSteganography) EmbedText(img image.Image, text string) (image.Image, error) {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
+	return d.EmbedBytes(img, []byte(text))
+}
 
-	// 检查图像尺寸
-	if width%d.blockSize != 0 || height%d.blockSize != 0 {
-		return nil, fmt.Errorf("图像尺寸必须是%d的倍数", d.blockSize)
+func (d *DCTSteganography) ExtractText(img image.Image) (string, error) {
+	data, err := d.ExtractBytes(img)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// EmbedBytes 嵌入任意二进制数据，数据中可以包含0字节
+func (d *DCTSteganography) EmbedBytes(img image.Image, data []byte) (image.Image, error) {
+	return Embed(d, img, data, nil)
+}
+
+// ExtractBytes 提取由EmbedBytes嵌入的二进制数据
+func (d *DCTSteganography) ExtractBytes(img image.Image) ([]byte, error) {
+	return Extract(d, img, nil)
+}
+
+func (d *DCTSteganography) algorithmID() uint8 {
+	return AlgorithmDCT
+}
+
+// 参数格式：低8位为JPEG目标质量（0表示符号嵌入），8–15位为使用的系数在
+// dctCoefficients中的位掩码（只用第一个时为0），16–23位为符号嵌入的强度
+// （默认强度为0），最高位表示交织。默认配置与早期版本的参数相同
+func (d *DCTSteganography) params() uint32 {
+	if d.sequential {
+		return 0
 	}
+	mask := d.coefficientMask()
+	if mask == 1 {
+		mask = 0
+	}
+	strength := d.strength()
+	if strength == dctDefaultStrength {
+		strength = 0
+	}
+	return paramInterleaved | uint32(strength)<<16 | uint32(mask)<<8 | uint32(d.JPEGQuality)
+}
 
-	// 将文本转换为比特流
-	bits := textToBits(text)
-	maxBits := (width * height) / (d.blockSize * d.blockSize)
-	if len(bits) > maxBits {
-		return nil, fmt.Errorf("文本太长，超出图像容量")
+// variants 枚举其他嵌入方式：交织之前的光栅顺序格式，以及各系数组合下的
+// 符号嵌入强度和JPEG目标质量，提取时用于识别嵌入时的配置
+func (d *DCTSteganography) variants() []carrier {
+	candidates := []*DCTSteganography{
+		{blockSize: d.blockSize},
+		{blockSize: d.blockSize, sequential: true},
+	}
+	for _, quality := range jpegQualities {
+		candidates = append(candidates, &DCTSteganography{JPEGQuality: quality, blockSize: d.blockSize})
+	}
+	for mask := 1; mask < 1<<len(dctCoefficients); mask++ {
+		coefs := maskCoefficients(uint8(mask))
+		for _, strength := range dctStrengths {
+			candidates = append(candidates, &DCTSteganography{Coefficients: coefs, Strength: strength, blockSize: d.blockSize})
+		}
+		for _, quality := range jpegQualities {
+			candidates = append(candidates, &DCTSteganography{Coefficients: coefs, JPEGQuality: quality, blockSize: d.blockSize})
+		}
 	}
 
-	// 创建输出图像
-	output := image.NewRGBA(bounds)
-	bitIndex := 0
+	cache := &dctCache{}
+	seen := map[uint32]bool{d.params(): true}
+	var variants []carrier
+	for _, c := range candidates {
+		if !seen[c.params()] {
+			seen[c.params()] = true
+			c.cache = cache
+			variants = append(variants, c)
+		}
+	}
+	return variants
+}
 
-	// 按块处理图像
-	for y := 0; y < height; y += d.blockSize {
-		for x := 0; x < width; x += d.blockSize {
-			if bitIndex >= len(bits) {
-				// 复制剩余的图像块
-				d.copyBlock(img, output, x, y)
-				continue
-			}
+// 检查配置是否有效
+func (d *DCTSteganography) validate() error {
+	if d.JPEGQuality != 0 && !slices.Contains(jpegQualities, d.JPEGQuality) {
+		return fmt.Errorf("JPEG质量必须是50到90之间5的倍数: %d", d.JPEGQuality)
+	}
+	if d.BitsPerBlock < 0 || d.BitsPerBlock > len(dctCoefficients) {
+		return fmt.Errorf("每块比特数必须在1到%d之间: %d", len(dctCoefficients), d.BitsPerBlock)
+	}
+	for i, c := range d.Coefficients {
+		if !slices.Contains(dctCoefficients[:], c) {
+			return fmt.Errorf("不支持的DCT系数位置 %s（可选 %s）", c, dctCoefficientNames())
+		}
+		if slices.Contains(d.Coefficients[:i], c) {
+			return fmt.Errorf("DCT系数位置 %s 重复", c)
+		}
+	}
+	if d.BitsPerBlock != 0 && len(d.Coefficients) != 0 && d.BitsPerBlock != len(d.Coefficients) {
+		return fmt.Errorf("每块比特数%d与指定的系数个数%d不一致", d.BitsPerBlock, len(d.Coefficients))
+	}
+	if d.Strength != 0 {
+		if d.JPEGQuality != 0 {
+			return fmt.Errorf("JPEG模式的改动量由目标质量决定，不能指定强度")
+		}
+		if !slices.Contains(dctStrengths, d.Strength) {
+			return fmt.Errorf("不支持的嵌入强度: %d（可选 %s）", d.Strength, joinInts(dctStrengths))
+		}
+	}
+	return nil
+}
 
-			// 提取块数据
-			block := d.getBlock(img, x, y)
-			// 预处理
-			block = d.preprocessBlock(block)
-			// DCT变换
-			dctBlock := d.dct2D(block)
+// 可选系数位置的列表，用于错误信息
+func dctCoefficientNames() string {
+	names := make([]string, len(dctCoefficients))
+	for i, c := range dctCoefficients {
+		names[i] = c.String()
+	}
+	return strings.Join(names, ", ")
+}
 
-			// 在中频系数中嵌入信息
-			if bits[bitIndex] == 1 {
-				dctBlock[4][3] = math.Abs(dctBlock[4][3]) + 25.0
-			} else {
-				dctBlock[4][3] = -math.Abs(dctBlock[4][3]) - 25.0
-			}
-			bitIndex++
+// 以逗号连接的整数列表，用于错误信息
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// 使用的系数在dctCoefficients中的位掩码
+func (d *DCTSteganography) coefficientMask() uint8 {
+	if len(d.Coefficients) == 0 {
+		return uint8(1<<max(d.BitsPerBlock, 1) - 1)
+	}
+	var mask uint8
+	for _, c := range d.Coefficients {
+		if i := slices.Index(dctCoefficients[:], c); i >= 0 {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
 
-			// 逆DCT变换
-			idctBlock := d.idct2D(dctBlock)
-			// 后处理
-			idctBlock = d.postprocessBlock(idctBlock)
-			// 写回图像
-			d.setBlock(output, idctBlock, x, y)
+// 位掩码对应的系数，按dctCoefficients的顺序排列
+func maskCoefficients(mask uint8) []DCTCoefficient {
+	var coefs []DCTCoefficient
+	for i, c := range dctCoefficients {
+		if mask&(1<<i) != 0 {
+			coefs = append(coefs, c)
 		}
 	}
+	return coefs
+}
 
-	return output, nil
+// 使用的系数，按dctCoefficients的顺序排列，与指定的顺序无关
+func (d *DCTSteganography) coefficients() []DCTCoefficient {
+	return maskCoefficients(d.coefficientMask())
 }
 
-func (d *DCTSteganography) ExtractText(img image.Image) (string, error) {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
+func (d *DCTSteganography) strength() int {
+	if d.Strength == 0 && d.JPEGQuality == 0 {
+		return dctDefaultStrength
+	}
+	return d.Strength
+}
 
-	var bits []int
+// QIM的量化步长：目标质量下该系数的JPEG量化步长的两倍，
+// 质量不低于目标时重新量化的误差不超过步长的四分之一
+func (d *DCTSteganography) jpegStep(c DCTCoefficient) float64 {
+	return jpegStepScale * jpegQuantStep(d.JPEGQuality, c.Row, c.Col)
+}
 
-	// 按块处理图像
-	for y := 0; y < height; y += d.blockSize {
-		for x := 0; x < width; x += d.blockSize {
-			// 提取块数据
-			block := d.getBlock(img, x, y)
+// 返回前n个比特依次使用的块下标，块按光栅顺序编号
+func (d *DCTSteganography) blockOrder(l layout, total, n int) []int {
+	if d.sequential {
+		return slotOrder("", total, n)
+	}
+	return interleaveOrder(l.key, total, n)
+}
 
-			// DCT变换
-			dctBlock := d.dct2D(block)
+func (d *DCTSteganography) capacityBits(bounds image.Rectangle) int {
+	return (bounds.Dx() / d.blockSize) * (bounds.Dy() / d.blockSize) * len(d.coefficients())
+}
 
-			// 从中频系数提取信息
-			if dctBlock[4][3] > 0 {
-				bits = append(bits, 1)
+// dctBlockBits 是同一块中携带的比特
+type dctBlockBits struct {
+	block int
+	// coefs[i] 为bits[i]所在系数在coefficients()中的下标
+	coefs, bits []int
+}
+
+// 嵌入位置按块编号，第b块的各系数依次占用b*k到b*k+k-1，k为每块的比特数。
+// 将各位置上的比特按块归集，块按首次出现的顺序排列
+func groupByBlock(order, bits []int, k int) []dctBlockBits {
+	index := make(map[int]int)
+	var groups []dctBlockBits
+	for i, slot := range order {
+		g, ok := index[slot/k]
+		if !ok {
+			g = len(groups)
+			index[slot/k] = g
+			groups = append(groups, dctBlockBits{block: slot / k})
+		}
+		groups[g].coefs = append(groups[g].coefs, slot%k)
+		groups[g].bits = append(groups[g].bits, bits[i])
+	}
+	return groups
+}
+
+func (d *DCTSteganography) embedBits(img image.Image, bits []int, l layout) (image.Image, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	// 检查图像尺寸
+	if err := d.Constraints().Check(img.Bounds()); err != nil {
+		return nil, err
+	}
+
+	// 只使用完全落在原始区域内的块，填充出的部分不携带数据
+	width := l.region.Dx()
+	if len(bits) > d.capacityBits(l.region) {
+		return nil, ErrTooLarge
+	}
+
+	// 拆分为YCbCr平面，只在亮度上嵌入，保留色度和透明度
+	planes := splitPlanes(img)
+
+	// 按交织顺序处理各块，剩余的图像块保持不变
+	cols := width / d.blockSize
+	coefs := d.coefficients()
+	groups := groupByBlock(d.blockOrder(l, d.capacityBits(l.region), len(bits)), bits, len(coefs))
+	for _, g := range groups {
+		x, y := g.block%cols*d.blockSize, g.block/cols*d.blockSize
+
+		// 提取块数据
+		block := d.getBlock(planes, x, y)
+		// 预处理
+		block = d.preprocessBlock(block)
+		// DCT变换
+		dctBlock := d.dct2D(block)
+
+		// 在中频系数中嵌入信息
+		for i, k := range g.coefs {
+			c := coefs[k]
+			if d.JPEGQuality > 0 {
+				dctBlock[c.Row][c.Col] = qimEmbed(dctBlock[c.Row][c.Col], d.jpegStep(c), g.bits[i])
+			} else if g.bits[i] == 1 {
+				dctBlock[c.Row][c.Col] = math.Abs(dctBlock[c.Row][c.Col]) + float64(d.strength())
 			} else {
-				bits = append(bits, 0)
+				dctBlock[c.Row][c.Col] = -math.Abs(dctBlock[c.Row][c.Col]) - float64(d.strength())
 			}
+		}
+
+		// 逆DCT变换
+		idctBlock := d.idct2D(dctBlock)
+		// 后处理
+		idctBlock = d.postprocessBlock(idctBlock)
+		// 写回图像
+		d.setBlock(planes, idctBlock, x, y)
+	}
+
+	if d.JPEGQuality > 0 {
+		d.correctBlocks(planes, groups, coefs, cols)
+	}
+	return planes.toImage(), nil
+}
+
+// JPEG模式下逐块校验嵌入结果：转换为8位RGB时的取整和截断可能使系数偏离格点。
+// 偏差较大的系数先按偏差反向补偿，多次仍失败（通常是像素被截断）时改用同一比特的
+// 另一个格点
+func (d *DCTSteganography) correctBlocks(planes *colorPlanes, groups []dctBlockBits, coefs []DCTCoefficient, cols int) {
+	for pass := 0; pass < maxCorrectionPasses; pass++ {
+		luma := lumaPlane(planes.toImage())
+		done := true
+		for _, g := range groups {
+			x, y := g.block%cols*d.blockSize, g.block/cols*d.blockSize
+			actual := d.dct2D(d.getBlock(luma, x, y))
+			var dctBlock [][]float64
+			for i, k := range g.coefs {
+				c := coefs[k]
+				step, a := d.jpegStep(c), actual[c.Row][c.Col]
+				if math.Abs(a-qimEmbed(a, step, g.bits[i])) < step/4 {
+					continue
+				}
+				done = false
 
-			// 检查结束标记
-			if len(bits)%8 == 0 {
-				text := bitsToText(bits)
-				if len(text) > 0 && text[len(text)-1] == 0 {
-					return text[:len(text)-1], nil
+				if dctBlock == nil {
+					dctBlock = d.dct2D(d.preprocessBlock(d.getBlock(planes, x, y)))
+				}
+				target := qimEmbed(dctBlock[c.Row][c.Col], step, g.bits[i])
+				if pass >= maxCorrectionPasses/2 {
+					// 补偿无效，说明朝该方向已无法调整，换到反方向的格点
+					if a < target {
+						target -= 2 * step
+					} else {
+						target += 2 * step
+					}
 				}
+				dctBlock[c.Row][c.Col] = target + (target - a)
 			}
+			if dctBlock != nil {
+				d.setBlock(planes, d.postprocessBlock(d.idct2D(dctBlock)), x, y)
+			}
+		}
+		if done {
+			return
+		}
+	}
+}
+
+func (d *DCTSteganography) extractBits(img image.Image, n int, l layout) ([]int, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	width := l.region.Dx()
+	if n > d.capacityBits(l.region) {
+		return nil, fmt.Errorf("图像太小，无法读取%d位数据", n)
+	}
+
+	blocks := d.cache
+	if blocks == nil {
+		blocks = &dctCache{}
+	}
+	bits := make([]int, 0, n)
+
+	// 按与嵌入相同的顺序处理各块
+	cols := width / d.blockSize
+	coefs := d.coefficients()
+	for _, slot := range d.blockOrder(l, d.capacityBits(l.region), n) {
+		block := slot / len(coefs)
+		// DCT变换
+		dctBlock := blocks.block(d, img, block%cols*d.blockSize, block/cols*d.blockSize)
+
+		// 从中频系数提取信息
+		c := coefs[slot%len(coefs)]
+		switch {
+		case d.JPEGQuality > 0:
+			bits = append(bits, qimExtract(dctBlock[c.Row][c.Col], d.jpegStep(c)))
+		case dctBlock[c.Row][c.Col] > 0:
+			bits = append(bits, 1)
+		default:
+			bits = append(bits, 0)
 		}
 	}
 
-	return bitsToText(bits), nil
+	return bits, nil
 }
 
-// 辅助方法：获取图像块
-func (d *DCTSteganography) getBlock(img image.Image, x, y int) [][]float64 {
+// dctCache 缓存一张图像的亮度和各块的DCT系数，与嵌入参数无关。
+// 只能用于同一张图像，variants()每次调用都会创建新的缓存
+type dctCache struct {
+	luma   *colorPlanes
+	blocks map[image.Point][][]float64
+}
+
+// 返回左上角位于(x, y)的块的DCT系数
+func (c *dctCache) block(d *DCTSteganography, img image.Image, x, y int) [][]float64 {
+	if c.luma == nil {
+		c.luma = lumaPlane(img)
+		c.blocks = make(map[image.Point][][]float64)
+	}
+	key := image.Point{x, y}
+	b, ok := c.blocks[key]
+	if !ok {
+		b = d.dct2D(d.getBlock(c.luma, x, y))
+		c.blocks[key] = b
+	}
+	return b
+}
+
+// 辅助方法：获取亮度块，坐标相对于图像左上角
+func (d *DCTSteganography) getBlock(planes *colorPlanes, x, y int) [][]float64 {
 	block := make([][]float64, d.blockSize)
 	for i := range block {
 		block[i] = make([]float64, d.blockSize)
 		for j := 0; j < d.blockSize; j++ {
-			r, _, _, _ := img.At(x+j, y+i).RGBA()
-			block[i][j] = float64(r >> 8)
+			block[i][j] = planes.luma(x+j, y+i)
 		}
 	}
 	return block
 }
 
-// 辅助方法：设置图像块
-func (d *DCTSteganography) setBlock(img *image.RGBA, block [][]float64, x, y int) {
+// 辅助方法：写回亮度块
+func (d *DCTSteganography) setBlock(planes *colorPlanes, block [][]float64, x, y int) {
 	for i := 0; i < d.blockSize; i++ {
 		for j := 0; j < d.blockSize; j++ {
-			val := uint8(math.Max(0, math.Min(255, block[i][j])))
-			img.Set(x+j, y+i, color.RGBA{val, val, val, 255})
+			planes.setLuma(x+j, y+i, math.Max(0, math.Min(255, block[i][j])))
 		}
 	}
 }
 
-// 辅助方法：复制图像块
-func (d *DCTSteganography) copyBlock(src image.Image, dst *image.RGBA, x, y int) {
-	for i := 0; i < d.blockSize; i++ {
-		for j := 0; j < d.blockSize; j++ {
-			dst.Set(x+j, y+i, src.At(x+j, y+i))
-		}
-	}
-}
-
-// 2D DCT变换
+// 2D DCT变换（DCT-II，正交归一化）。按行列分离计算：先对每列做一维变换，
+// 再对每行做一维变换，基函数的余弦值预先算好，每块只需2n³次乘加
 func (d *DCTSteganography) dct2D(block [][]float64) [][]float64 {
 	n := d.blockSize
-	result := make([][]float64, n)
-	for i := range result {
-		result[i] = make([]float64, n)
-	}
+	basis := dctBasis(n)
 
-	// 计算DCT系数
+	// temp[u][y] = Σx basis[u][x]·block[x][y]
+	temp := make([]float64, n*n)
 	for u := 0; u < n; u++ {
-		for v := 0; v < n; v++ {
-			var sum float64
-			for x := 0; x < n; x++ {
-				for y := 0; y < n; y++ {
-					// DCT变换公式
-					cos1 := math.Cos((2*float64(x) + 1) * float64(u) * math.Pi / (2 * float64(n)))
-					cos2 := math.Cos((2*float64(y) + 1) * float64(v) * math.Pi / (2 * float64(n)))
-					sum += block[x][y] * cos1 * cos2
-				}
+		bu := basis[u*n : u*n+n]
+		row := temp[u*n : u*n+n]
+		for x, c := range bu {
+			for y, v := range block[x][:n] {
+				row[y] += c * v
 			}
+		}
+	}
 
-			// 计算系数
-			cu := 1.0
-			cv := 1.0
-			if u == 0 {
-				cu = 1.0 / math.Sqrt(2)
-			}
-			if v == 0 {
-				cv = 1.0 / math.Sqrt(2)
+	// result[u][v] = Σy temp[u][y]·basis[v][y]
+	result := make([][]float64, n)
+	for u := range result {
+		result[u] = make([]float64, n)
+		row := temp[u*n : u*n+n]
+		for v := 0; v < n; v++ {
+			bv := basis[v*n : v*n+n]
+			var sum float64
+			for y, t := range row {
+				sum += t * bv[y]
 			}
-
-			// 保存结果
-			result[u][v] = (2 * cu * cv * sum) / float64(n)
+			result[u][v] = sum
 		}
 	}
-
 	return result
 }
 
-// 2D 逆DCT变换
+// 2D 逆DCT变换，basis为正交矩阵，逆变换即转置
 func (d *DCTSteganography) idct2D(block [][]float64) [][]float64 {
 	n := d.blockSize
-	result := make([][]float64, n)
-	for i := range result {
-		result[i] = make([]float64, n)
-	}
-
-	// 计算逆DCT
-	for x := 0; x < n; x++ {
-		for y := 0; y < n; y++ {
-			var sum float64
-			for u := 0; u < n; u++ {
-				for v := 0; v < n; v++ {
-					// 计算系数
-					cu := 1.0
-					cv := 1.0
-					if u == 0 {
-						cu = 1.0 / math.Sqrt(2)
-					}
-					if v == 0 {
-						cv = 1.0 / math.Sqrt(2)
-					}
+	basis := dctBasis(n)
 
-					// 逆DCT变换公式
-					cos1 := math.Cos((2*float64(x) + 1) * float64(u) * math.Pi / (2 * float64(n)))
-					cos2 := math.Cos((2*float64(y) + 1) * float64(v) * math.Pi / (2 * float64(n)))
-					sum += cu * cv * block[u][v] * cos1 * cos2
-				}
+	// temp[x][v] = Σu basis[u][x]·block[u][v]
+	temp := make([]float64, n*n)
+	for u := 0; u < n; u++ {
+		bu := basis[u*n : u*n+n]
+		coefs := block[u][:n]
+		for x, c := range bu {
+			row := temp[x*n : x*n+n]
+			for v, b := range coefs {
+				row[v] += c * b
 			}
-
-			// 保存结果
-			result[x][y] = (2 * sum) / float64(n)
 		}
 	}
 
+	// result[x][y] = Σv temp[x][v]·basis[v][y]
+	result := make([][]float64, n)
+	for x := range result {
+		result[x] = make([]float64, n)
+		for v, t := range temp[x*n : x*n+n] {
+			bv := basis[v*n : v*n+n]
+			for y := range result[x] {
+				result[x][y] += t * bv[y]
+			}
+		}
+	}
 	return result
 }
 
+// 各块大小的DCT基矩阵，按需计算后缓存
+var dctBases sync.Map // int -> []float64
+
+// 返回n×n的DCT基矩阵，按行存储：basis[u*n+x] = c(u)·√(2/n)·cos((2x+1)uπ/2n)，
+// 其中c(0) = 1/√2，其余为1
+func dctBasis(n int) []float64 {
+	if basis, ok := dctBases.Load(n); ok {
+		return basis.([]float64)
+	}
+	basis := make([]float64, n*n)
+	for u := 0; u < n; u++ {
+		c := math.Sqrt(2 / float64(n))
+		if u == 0 {
+			c /= math.Sqrt(2)
+		}
+		for x := 0; x < n; x++ {
+			basis[u*n+x] = c * math.Cos((2*float64(x)+1)*float64(u)*math.Pi/(2*float64(n)))
+		}
+	}
+	actual, _ := dctBases.LoadOrStore(n, basis)
+	return actual.([]float64)
+}
+
 // 添加一个辅助方法来预处理图像块
 func (d *DCTSteganography) preprocessBlock(block [][]float64) [][]float64 {
 	n := d.blockSize
@@ -1,9 +1,14 @@
 package steganography
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -20,25 +25,25 @@ func TestDCTSteganography_EmbedAndExtract(t *testing.T) {
 		{
 			name:    "基本ASCII文本",
 			text:    "Hello, World!",
-			imgSize: 128, // 必须是8的倍数
+			imgSize: 256, // 必须是8的倍数
 			wantErr: false,
 		},
 		{
 			name:    "中文文本",
 			text:    "你好，世界！",
-			imgSize: 128,
+			imgSize: 256,
 			wantErr: false,
 		},
 		{
 			name:    "空文本",
 			text:    "",
-			imgSize: 128,
+			imgSize: 256,
 			wantErr: false,
 		},
 		{
 			name:    "特殊字符",
 			text:    "!@#$%^&*()_+{}[]|\\:;\"'<>,.?/~`",
-			imgSize: 128,
+			imgSize: 256,
 			wantErr: false,
 		},
 		{
@@ -122,3 +127,241 @@ func TestDCT_Transform(t *testing.T) {
 		}
 	}
 }
+
+func TestDCT_JPEGReencode(t *testing.T) {
+	// 带纹理的图像，接近照片的统计特性
+	rng := rand.New(rand.NewPCG(3, 4))
+	cover := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			cover.Set(x, y, color.RGBA{uint8(x/2 + rng.IntN(40)), uint8(y/2 + rng.IntN(40)), uint8((x + y) / 4), 255})
+		}
+	}
+	text := "survives JPEG re-encoding"
+
+	for _, target := range []int{50, 75, 90} {
+		encoded, err := NewDCTWithJPEGQuality(target).EmbedText(cover, text)
+		if err != nil {
+			t.Fatalf("quality %d: EmbedText() error = %v", target, err)
+		}
+
+		for _, quality := range []int{target, target + 3, 100} {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, encoded, &jpeg.Options{Quality: quality}); err != nil {
+				t.Fatal(err)
+			}
+			reencoded, err := jpeg.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 默认配置的DCT应能通过负载头识别目标质量
+			got, err := NewDCTSteganography().ExtractText(reencoded)
+			if err != nil || got != text {
+				t.Errorf("target %d, re-encoded at %d: ExtractText() = %q, %v; want %q", target, quality, got, err, text)
+			}
+		}
+	}
+
+	if _, err := NewDCTWithJPEGQuality(42).EmbedText(cover, text); err == nil {
+		t.Error("EmbedText() with unsupported quality succeeded")
+	}
+}
+
+func TestDCT_Coefficients(t *testing.T) {
+	cover := addNoise(newGradientImage(256), 20, 5)
+	text := strings.Repeat("系数位置与强度", 4)
+	base := NewDCTSteganography().Capacity(cover.Bounds())
+
+	configs := []struct {
+		name string
+		dct  *DCTSteganography
+		bits int
+	}{
+		{"每块3位", &DCTSteganography{BitsPerBlock: 3}, 3},
+		{"指定系数", &DCTSteganography{Coefficients: []DCTCoefficient{{4, 4}, {2, 5}}}, 2},
+		{"低强度", &DCTSteganography{Strength: 10}, 1},
+		{"每块8位高强度", &DCTSteganography{BitsPerBlock: 8, Strength: 50}, 8},
+		{"JPEG模式每块2位", &DCTSteganography{JPEGQuality: 75, BitsPerBlock: 2}, 2},
+	}
+	for _, tc := range configs {
+		tc.dct.blockSize = 8
+		if got := tc.dct.capacityBits(cover.Bounds()); got != 32*32*tc.bits {
+			t.Errorf("%s: capacityBits() = %d; want %d", tc.name, got, 32*32*tc.bits)
+		}
+		if tc.bits > 1 && tc.dct.Capacity(cover.Bounds()) <= base {
+			t.Errorf("%s: Capacity() = %d; want more than %d", tc.name, tc.dct.Capacity(cover.Bounds()), base)
+		}
+		encoded, err := tc.dct.EmbedText(cover, text)
+		if err != nil {
+			t.Fatalf("%s: EmbedText() error = %v", tc.name, err)
+		}
+		if tc.dct.JPEGQuality > 0 {
+			encoded = reencodeJPEG(t, encoded, 80)
+		}
+		// 默认配置的DCT通过负载头识别系数和强度
+		if got, err := NewDCTSteganography().ExtractText(encoded); err != nil || got != text {
+			t.Errorf("%s: ExtractText() = %q, %v; want %q", tc.name, got, err, text)
+		}
+	}
+
+	// 强度越低改动越小
+	weak, _ := (&DCTSteganography{Strength: 10, blockSize: 8}).EmbedText(cover, text)
+	strong, _ := (&DCTSteganography{Strength: 50, blockSize: 8}).EmbedText(cover, text)
+	if weakMSE, strongMSE := meanSquaredError(cover, weak), meanSquaredError(cover, strong); weakMSE >= strongMSE {
+		t.Errorf("MSE with strength 10 = %.2f; strength 50 = %.2f", weakMSE, strongMSE)
+	}
+
+	// 默认配置的参数与早期版本相同
+	if got := NewDCTSteganography().params(); got != paramInterleaved {
+		t.Errorf("default params() = %#x; want %#x", got, paramInterleaved)
+	}
+
+	invalid := []*DCTSteganography{
+		{Coefficients: []DCTCoefficient{{0, 0}}},
+		{Coefficients: []DCTCoefficient{{4, 3}, {4, 3}}},
+		{Coefficients: []DCTCoefficient{{4, 3}}, BitsPerBlock: 2},
+		{BitsPerBlock: 9},
+		{Strength: 12},
+		{Strength: 25, JPEGQuality: 75},
+	}
+	for _, d := range invalid {
+		d.blockSize = 8
+		if _, err := d.EmbedText(cover, "x"); err == nil {
+			t.Errorf("EmbedText() with %+v succeeded", *d)
+		}
+	}
+}
+
+func TestParseDCTCoefficients(t *testing.T) {
+	got, err := ParseDCTCoefficients("4:3, 2:5")
+	if err != nil || !slices.Equal(got, []DCTCoefficient{{4, 3}, {2, 5}}) {
+		t.Errorf("ParseDCTCoefficients() = %v, %v", got, err)
+	}
+	for _, spec := range []string{"", "4", "4,3", "a:b"} {
+		if _, err := ParseDCTCoefficients(spec); err == nil {
+			t.Errorf("ParseDCTCoefficients(%q) succeeded", spec)
+		}
+	}
+}
+
+// 逐项计算余弦的DCT，与dct2D对照
+func naiveDCT2D(block [][]float64) [][]float64 {
+	n := len(block)
+	result := make([][]float64, n)
+	for u := range result {
+		result[u] = make([]float64, n)
+		for v := 0; v < n; v++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				for y := 0; y < n; y++ {
+					cos1 := math.Cos((2*float64(x) + 1) * float64(u) * math.Pi / (2 * float64(n)))
+					cos2 := math.Cos((2*float64(y) + 1) * float64(v) * math.Pi / (2 * float64(n)))
+					sum += block[x][y] * cos1 * cos2
+				}
+			}
+			cu, cv := 1.0, 1.0
+			if u == 0 {
+				cu = 1 / math.Sqrt(2)
+			}
+			if v == 0 {
+				cv = 1 / math.Sqrt(2)
+			}
+			result[u][v] = 2 * cu * cv * sum / float64(n)
+		}
+	}
+	return result
+}
+
+// 逐项计算余弦的逆DCT，与idct2D对照
+func naiveIDCT2D(block [][]float64) [][]float64 {
+	n := len(block)
+	result := make([][]float64, n)
+	for x := range result {
+		result[x] = make([]float64, n)
+		for y := 0; y < n; y++ {
+			var sum float64
+			for u := 0; u < n; u++ {
+				for v := 0; v < n; v++ {
+					cu, cv := 1.0, 1.0
+					if u == 0 {
+						cu = 1 / math.Sqrt(2)
+					}
+					if v == 0 {
+						cv = 1 / math.Sqrt(2)
+					}
+					cos1 := math.Cos((2*float64(x) + 1) * float64(u) * math.Pi / (2 * float64(n)))
+					cos2 := math.Cos((2*float64(y) + 1) * float64(v) * math.Pi / (2 * float64(n)))
+					sum += cu * cv * block[u][v] * cos1 * cos2
+				}
+			}
+			result[x][y] = 2 * sum / float64(n)
+		}
+	}
+	return result
+}
+
+func randomBlock(rng *rand.Rand, n int) [][]float64 {
+	block := make([][]float64, n)
+	for i := range block {
+		block[i] = make([]float64, n)
+		for j := range block[i] {
+			block[i][j] = rng.Float64()*255 - 128
+		}
+	}
+	return block
+}
+
+func TestDCT_MatchesNaive(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 8))
+	for _, n := range []int{4, 8, 16} {
+		d := &DCTSteganography{blockSize: n}
+		for trial := 0; trial < 20; trial++ {
+			block := randomBlock(rng, n)
+			for name, pair := range map[string][2][][]float64{
+				"dct2D":  {d.dct2D(block), naiveDCT2D(block)},
+				"idct2D": {d.idct2D(block), naiveIDCT2D(block)},
+			} {
+				for i := range block {
+					for j := range block[i] {
+						if diff := math.Abs(pair[0][i][j] - pair[1][i][j]); diff > 1e-9 {
+							t.Fatalf("n=%d %s[%d][%d] = %v; naive %v", n, name, i, j, pair[0][i][j], pair[1][i][j])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkDCT2D(b *testing.B) {
+	d := NewDCTSteganography()
+	block := randomBlock(rand.New(rand.NewPCG(1, 2)), 8)
+	b.Run("separable", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			d.idct2D(d.dct2D(block))
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			naiveIDCT2D(naiveDCT2D(block))
+		}
+	})
+}
+
+// 在4K图像的所有块中嵌入
+func BenchmarkDCT_Embed4K(b *testing.B) {
+	cover := image.NewRGBA(image.Rect(0, 0, 3840, 2160))
+	rng := rand.New(rand.NewPCG(3, 4))
+	for i := range cover.Pix {
+		cover.Pix[i] = uint8(rng.IntN(256))
+	}
+	d := NewDCTSteganography()
+	data := make([]byte, d.Capacity(cover.Bounds()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := d.EmbedBytes(cover, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
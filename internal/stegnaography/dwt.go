@@ -3,41 +3,186 @@ package steganography
 import (
 	"fmt"
 	"image"
-	"image/color"
 	"math"
+	"slices"
 )
 
-type DWTSteganography struct{}
+// QIM模式支持的步长。提取时需要枚举这些步长读取负载头，因此只允许有限的取值
+var dwtQIMSteps = []int{4, 6, 8, 12, 16, 24, 32, 48, 64}
+
+// 符号嵌入时HL系数被推离0的距离
+const dwtSignOffset = 20
+
+// 最大分解级数
+const dwtMaxLevels = 4
+
+// Daubechies-4和CDF 9/7的系数受相邻像素影响，跳过最后一级子带中距区域边缘
+// 这么多行列的系数，使用的系数只取决于原始区域内的像素，不受填充内容影响
+const dwtBoundaryCells = 4
+
+// SelectTextured一级时的幅值阈值，每增加一级翻倍，与自然图像中细节系数
+// 随级数增大的趋势大致相同
+const dwtTextureThreshold = 4
+
+// SelectTextured时的最大补偿次数。多个子带同一位置的系数共用像素，
+// 补偿其中一个会扰动其余的，需要更多轮才能让所有系数都落在阈值的正确一侧
+const dwtTexturedCorrectionPasses = 16
+
+// 帧的前这么多位总是写在HL子带中，位置与使用的子带无关，足以容纳纠错帧的
+// 负载头码字。提取时按其他参数读出负载头后即可从中得到子带，不必逐个枚举
+const dwtHeaderBits = eccHeaderCodeword * 8
+
+type DWTSteganography struct {
+	// QIMStep 非0时对HL系数做抖动量化索引调制（QIM），取值为dwtQIMSteps之一。
+	// 步长越大越能经受噪声和有损处理，对图像的改动也越大；改动量不超过一个步长，
+	// 平坦区域不会像符号嵌入那样被强行推到±20。为0时使用符号嵌入。
+	// 步长以一级系数为单位，每增加一级实际步长翻倍，使像素上的改动量与级数无关
+	QIMStep int
+
+	// Wavelet 为使用的小波，默认为Haar
+	Wavelet Wavelet
+
+	// Levels 为分解级数（1–4），0视为1。数据嵌入最后一级的HL子带，
+	// 级数越多每个系数覆盖的像素越多，越能经受缩放和模糊，容量也越小
+	Levels int
+
+	// Subbands 为嵌入使用的最后一级细节子带，0视为HL。使用多个子带时容量成倍增加
+	Subbands Subband
+
+	// Selection 决定子带中哪些系数用于嵌入
+	Selection CoefficientSelection
+
+	// sequential 为true时按光栅顺序使用各系数，用于读取交织之前嵌入的图像
+	sequential bool
+
+	// cache 由variants()创建的各组合共享，缓存同一张图像的分解结果
+	cache *dwtCache
+}
 
 func NewDWTSteganography() *DWTSteganography {
 	return &DWTSteganography{}
 }
 
-// Haar小波变换
+// NewDWTWithQIMStep 创建使用指定QIM步长的DWT
+func NewDWTWithQIMStep(step int) *DWTSteganography {
+	return &DWTSteganography{QIMStep: step}
+}
+
+// NewDWTWithWavelet 创建使用指定小波和分解级数的DWT
+func NewDWTWithWavelet(w Wavelet, levels int) *DWTSteganography {
+	return &DWTSteganography{Wavelet: w, Levels: levels}
+}
+
+// 一维小波变换
 func (d *DWTSteganography) dwt1D(data []float64) ([]float64, []float64) {
-	n := len(data)
-	approx := make([]float64, n/2)
-	detail := make([]float64, n/2)
+	return d.Wavelet.forward(data)
+}
 
-	for i := 0; i < n/2; i++ {
-		approx[i] = (data[2*i] + data[2*i+1]) / math.Sqrt(2)
-		detail[i] = (data[2*i] - data[2*i+1]) / math.Sqrt(2)
+// 一维逆小波变换
+func (d *DWTSteganography) idwt1D(approx, detail []float64) []float64 {
+	return d.Wavelet.inverse(approx, detail)
+}
+
+func (d *DWTSteganography) levels() int {
+	return max(d.Levels, 1)
+}
+
+// 实际使用的QIM步长。平坦区域中像素取整到整数后，系数只能按一定的粒度变化，
+// 每增加一级粒度翻倍，步长不随级数放大时深层系数无法落到目标格点上
+func (d *DWTSteganography) qimStep() float64 {
+	return math.Ldexp(float64(d.QIMStep), d.levels()-1)
+}
+
+func (d *DWTSteganography) subbands() Subband {
+	if d.Subbands == 0 {
+		return SubbandHL
 	}
+	return d.Subbands
+}
 
-	return approx, detail
+// 使用的子带在dwtPyramid.details中的下标
+func (d *DWTSteganography) subbandIndexes() []int {
+	var indexes []int
+	for i := range subbandNames {
+		if d.subbands()&(1<<i) != 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
 }
 
-// 逆Haar小波变换
-func (d *DWTSteganography) idwt1D(approx, detail []float64) []float64 {
-	n := len(approx) * 2
-	result := make([]float64, n)
+// dwtPyramid 是多级分解的结果
+type dwtPyramid struct {
+	// ll 为最后一级的LL子带
+	ll [][]float64
+	// details[k] 为第k+1级的LH、HL、HH子带
+	details [][3][][]float64
+}
+
+// subband 返回最后一级的细节子带，数据嵌入其中
+func (p *dwtPyramid) subband(index int) [][]float64 {
+	return p.details[len(p.details)-1][index]
+}
+
+// 对LL子带逐级分解
+func (d *DWTSteganography) decompose(m [][]float64) *dwtPyramid {
+	p := &dwtPyramid{ll: m}
+	for k := 0; k < d.levels(); k++ {
+		ll, lh, hl, hh := d.dwt2D(p.ll)
+		p.ll = ll
+		p.details = append(p.details, [3][][]float64{lh, hl, hh})
+	}
+	return p
+}
 
-	for i := 0; i < len(approx); i++ {
-		result[2*i] = (approx[i] + detail[i]) / math.Sqrt(2)
-		result[2*i+1] = (approx[i] - detail[i]) / math.Sqrt(2)
+// 从最后一级开始逐级重建
+func (d *DWTSteganography) reconstruct(p *dwtPyramid) [][]float64 {
+	m := p.ll
+	for k := len(p.details) - 1; k >= 0; k-- {
+		m = d.idwt2D(m, p.details[k][0], p.details[k][1], p.details[k][2])
 	}
+	return m
+}
 
-	return result
+// 分解图像的亮度。由variants()创建的组合共享缓存，枚举参数时
+// 相同小波和级数的组合只需分解一次
+func (d *DWTSteganography) analyze(img image.Image) *dwtPyramid {
+	if d.cache == nil {
+		bounds := img.Bounds()
+		return d.decompose(lumaMatrix(lumaPlane(img), bounds.Dx(), bounds.Dy()))
+	}
+	return d.cache.pyramid(d, img)
+}
+
+// dwtCache 缓存一张图像的亮度、在各小波和级数下的分解结果，以及SelectTextured
+// 选出的系数（与QIM步长无关）。只能用于同一张图像和布局，
+// variants()每次调用都会创建新的缓存
+type dwtCache struct {
+	luma       *colorPlanes
+	pyramids   map[uint32]*dwtPyramid
+	selections map[dwtSelectionKey][]int
+}
+
+type dwtSelectionKey struct {
+	params uint32
+	n      int
+}
+
+func (c *dwtCache) pyramid(d *DWTSteganography, img image.Image) *dwtPyramid {
+	if c.pyramids == nil {
+		c.pyramids = make(map[uint32]*dwtPyramid)
+	}
+	key := d.params() & dwtTransformMask
+	p, ok := c.pyramids[key]
+	if !ok {
+		if c.luma == nil || c.luma.bounds != img.Bounds() {
+			c.luma = lumaPlane(img)
+		}
+		bounds := img.Bounds()
+		p = d.decompose(lumaMatrix(c.luma, bounds.Dx(), bounds.Dy()))
+		c.pyramids[key] = p
+	}
+	return p
 }
 
 // 2D DWT变换
@@ -95,134 +240,490 @@ func (d *DWTSteganography) dwt2D(img [][]float64) ([][]float64, [][]float64, [][
 	return ll, lh, hl, hh
 }
 
+// Name 返回算法名称
+func (d *DWTSteganography) Name() string {
+	return "DWT"
+}
+
+// Constraints 每级变换将宽高减半，宽高须为2^级数的倍数
+func (d *DWTSteganography) Constraints() Constraints {
+	return Constraints{BlockSize: 1 << d.levels()}
+}
+
+// 提取时可能枚举任意级数，一次填充到满足最大级数的要求
+func (d *DWTSteganography) extractConstraints() Constraints {
+	return Constraints{BlockSize: 1 << dwtMaxLevels}
+}
+
+// Capacity 使用最后一级HL子带的一部分存储数据，需扣除负载头
+func (d *DWTSteganography) Capacity(bounds image.Rectangle) int {
+	return payloadCapacity(d, bounds)
+}
+
 func (d *DWTSteganography) EmbedText(img image.Image, text string) (image.Image, error) {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
+	return d.EmbedBytes(img, []byte(text))
+}
+
+func (d *DWTSteganography) ExtractText(img image.Image) (string, error) {
+	data, err := d.ExtractBytes(img)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// EmbedBytes 嵌入任意二进制数据，数据中可以包含0字节
+func (d *DWTSteganography) EmbedBytes(img image.Image, data []byte) (image.Image, error) {
+	return Embed(d, img, data, nil)
+}
+
+// ExtractBytes 提取由EmbedBytes嵌入的二进制数据
+func (d *DWTSteganography) ExtractBytes(img image.Image) ([]byte, error) {
+	return Extract(d, img, nil)
+}
 
-	// 确保图像尺寸是2的幂
-	if !isPowerOfTwo(width) || !isPowerOfTwo(height) {
-		return nil, fmt.Errorf("图像尺寸必须是2的幂")
+func (d *DWTSteganography) algorithmID() uint8 {
+	return AlgorithmDWT
+}
+
+// 参数中决定变换方式的位：分解级数和小波
+const dwtTransformMask = 0xff00
+
+// 参数中表示子带的位，不影响负载头的位置
+const dwtSubbandMask = 0xf0000
+
+// 参数格式：低8位为QIM步长（0表示符号嵌入），8–11位为分解级数减1，
+// 12–15位为小波，16–18位为子带（只用HL时为0），20–23位为系数选择方式，
+// 最高位表示交织。默认配置的参数与早期版本相同
+func (d *DWTSteganography) params() uint32 {
+	if d.sequential {
+		return 0
+	}
+	subbands := d.subbands()
+	if subbands == SubbandHL {
+		subbands = 0
 	}
+	return paramInterleaved | uint32(d.Selection)<<20 | uint32(subbands)<<16 |
+		uint32(d.Wavelet)<<12 | uint32(d.levels()-1)<<8 | uint32(d.QIMStep)
+}
 
-	// 将文本转换为比特流
-	bits := textToBits(text)
-	if len(bits) > (width*height)/64 { // 使用HL子带嵌入信息
-		return nil, fmt.Errorf("文本太长，超出图像容量")
+// variants 枚举其他嵌入方式：交织之前的光栅顺序格式，以及各小波、分解级数、
+// QIM步长和系数选择方式的组合，提取时用于识别嵌入时的配置。负载头的位置与
+// 子带无关，子带由withParams按负载头确定，不在此枚举
+func (d *DWTSteganography) variants() []carrier {
+	cache := &dwtCache{}
+	candidates := []*DWTSteganography{{sequential: true}}
+	for w := Wavelet(0); w < waveletCount; w++ {
+		for levels := 1; levels <= dwtMaxLevels; levels++ {
+			for sel := CoefficientSelection(0); sel < selectionCount; sel++ {
+				for _, step := range append([]int{0}, dwtQIMSteps...) {
+					candidates = append(candidates, &DWTSteganography{
+						QIMStep: step, Wavelet: w, Levels: levels, Selection: sel,
+					})
+				}
+			}
+		}
 	}
 
-	// 准备图像数据
-	imgData := make([][]float64, height)
-	for i := range imgData {
-		imgData[i] = make([]float64, width)
-		for j := 0; j < width; j++ {
-			r, _, _, _ := img.At(j, i).RGBA()
-			imgData[i][j] = float64(r >> 8)
+	// 与d只有子带不同的组合读到的负载头与d相同，无需再试
+	var variants []carrier
+	for _, c := range candidates {
+		if c.params() != d.params()&^dwtSubbandMask {
+			c.cache = cache
+			variants = append(variants, c)
 		}
 	}
+	return variants
+}
 
-	// DWT变换
-	ll, lh, hl, hh := d.dwt2D(imgData)
-
-	// 在HL子带中嵌入信息
-	bitIndex := 0
-	for i := 0; i < len(hl) && bitIndex < len(bits); i++ {
-		for j := 0; j < len(hl[0]) && bitIndex < len(bits); j++ {
-			if bits[bitIndex] == 1 {
-				hl[i][j] = math.Abs(hl[i][j]) + 20
+// withParams 返回按负载头中的参数读取数据的载体。只有子带不同时负载头的位置
+// 相同，其余参数不同时返回nil
+func (d *DWTSteganography) withParams(params uint32) carrier {
+	if d.sequential || params&^dwtSubbandMask != d.params()&^dwtSubbandMask {
+		return nil
+	}
+	c := &DWTSteganography{
+		QIMStep: d.QIMStep, Wavelet: d.Wavelet, Levels: d.Levels, Selection: d.Selection,
+		Subbands: Subband(params & dwtSubbandMask >> 16), cache: d.cache,
+	}
+	if c.validate() != nil {
+		return nil
+	}
+	return c
+}
+
+// 写入负载头的载体：只使用HL子带，其余参数与d相同。d本身只使用HL子带时返回nil
+func (d *DWTSteganography) headerCarrier() *DWTSteganography {
+	if d.sequential || d.subbands() == SubbandHL {
+		return nil
+	}
+	return &DWTSteganography{QIMStep: d.QIMStep, Wavelet: d.Wavelet, Levels: d.Levels, Selection: d.Selection, cache: d.cache}
+}
+
+// 检查配置是否有效
+func (d *DWTSteganography) validate() error {
+	if d.QIMStep != 0 && !slices.Contains(dwtQIMSteps, d.QIMStep) {
+		return fmt.Errorf("QIM步长必须是%v之一: %d", dwtQIMSteps, d.QIMStep)
+	}
+	if d.Wavelet >= waveletCount {
+		return fmt.Errorf("未知的小波: %d", d.Wavelet)
+	}
+	if d.Levels < 0 || d.Levels > dwtMaxLevels {
+		return fmt.Errorf("分解级数必须在1到%d之间: %d", dwtMaxLevels, d.Levels)
+	}
+	if d.Subbands&^subbandAll != 0 {
+		return fmt.Errorf("未知的子带: %#x", uint8(d.Subbands))
+	}
+	if d.Selection >= selectionCount {
+		return fmt.Errorf("未知的系数选择方式: %d", d.Selection)
+	}
+	return nil
+}
+
+// 返回前n个系数序号（按dwtGrid编号）。使用的各子带的系数依次排列，交织时在
+// 原始区域内的全部系数中选取，使数据分散到整张图像；光栅顺序下与早期版本一致
+func (d *DWTSteganography) cellOrder(l layout, n int) []int {
+	rows, cols := d.regionCells(l)
+	bands := d.subbandIndexes()
+	var order []int
+	if d.sequential {
+		order = slotOrder("", rows*cols*len(bands), n)
+	} else {
+		order = interleaveOrder(l.key, rows*cols*len(bands), n)
+	}
+	size := rows * cols
+	for i, cell := range order {
+		order[i] = bands[cell/size]*size + cell%size
+	}
+	return order
+}
+
+// 按遍历顺序返回携带前n个比特的系数序号。SelectTextured时跳过幅值低于阈值的
+// 系数，同时返回途经而被跳过的系数（使用缓存时不返回）；可用的系数不足时
+// 返回的序号少于n个
+func (d *DWTSteganography) selectCells(g *dwtGrid, l layout, n int) (cells, skipped []int) {
+	if d.Selection == SelectAll && d.headerCarrier() == nil {
+		return d.cellOrder(l, n), nil
+	}
+	if d.cache == nil {
+		return d.walkCells(g, l, n)
+	}
+
+	key := dwtSelectionKey{d.params() &^ 0xff, n}
+	cells, ok := d.cache.selections[key]
+	if !ok {
+		cells, _ = d.walkCells(g, l, n)
+		if d.cache.selections == nil {
+			d.cache.selections = make(map[dwtSelectionKey][]int)
+		}
+		d.cache.selections[key] = cells
+	}
+	return cells, nil
+}
+
+// 按遍历顺序检查各系数的幅值，直到选出n个系数或遍历完全部系数。
+// 不只使用HL子带时，前dwtHeaderBits位由headerCarrier选出，其余比特跳过这些系数
+func (d *DWTSteganography) walkCells(g *dwtGrid, l layout, n int) (cells, skipped []int) {
+	reserved := make(map[int]bool)
+	if header := d.headerCarrier(); header != nil {
+		h := min(n, dwtHeaderBits)
+		cells, skipped = header.walkCells(g, l, h)
+		if len(cells) < h || len(cells) == n {
+			return cells, skipped
+		}
+		for _, cell := range cells {
+			reserved[cell] = true
+		}
+	}
+	prefix, skippedPrefix := len(cells), len(skipped)
+
+	rows, cols := d.regionCells(l)
+	total := rows * cols * len(d.subbandIndexes())
+	// 遍历顺序的前缀与展开的长度无关，先展开一部分，不够时再加倍
+	for m := min(2*n+64, total); ; m = min(2*m, total) {
+		cells, skipped = cells[:prefix], skipped[:skippedPrefix]
+		for _, cell := range d.cellOrder(l, m) {
+			if len(cells) == n {
+				break
+			}
+			if reserved[cell] {
+				continue
+			}
+			if d.Selection == SelectAll || math.Abs(*g.at(cell)) >= d.threshold() {
+				cells = append(cells, cell)
 			} else {
-				hl[i][j] = -math.Abs(hl[i][j]) - 20
+				skipped = append(skipped, cell)
 			}
-			bitIndex++
+		}
+		if len(cells) == n || m == total {
+			return cells, skipped
 		}
 	}
+}
 
-	// 逆变换
-	result := d.idwt2D(ll, lh, hl, hh)
+// dwtGrid 将系数序号对应到最后一级子带中的系数，LH、HL、HH子带的可用系数
+// 依次编号，负载头和数据使用的子带不同时也能统一表示
+type dwtGrid struct {
+	bands      [][][]float64
+	rows, cols int
+	margin     int
+}
 
-	// 创建结果图像
-	outputImg := image.NewRGBA(bounds)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			val := uint8(math.Max(0, math.Min(255, result[y][x])))
-			outputImg.Set(x, y, color.RGBA{val, val, val, 255})
-		}
+func (d *DWTSteganography) grid(p *dwtPyramid, l layout) *dwtGrid {
+	rows, cols := d.regionCells(l)
+	g := &dwtGrid{rows: rows, cols: cols, margin: d.boundaryMargin()}
+	for i := range subbandNames {
+		g.bands = append(g.bands, p.subband(i))
 	}
+	return g
+}
 
-	return outputImg, nil
+// 系数序号对应的系数
+func (g *dwtGrid) at(cell int) *float64 {
+	n := g.rows * g.cols
+	band := g.bands[cell/n]
+	cell %= n
+	return &band[cell/g.cols+g.margin][cell%g.cols+g.margin]
 }
 
-func (d *DWTSteganography) ExtractText(img image.Image) (string, error) {
+// SelectTextured的幅值阈值
+func (d *DWTSteganography) threshold() float64 {
+	return math.Ldexp(dwtTextureThreshold, d.levels()-1)
+}
+
+// 一级时每64个像素存储1位，即使用HL子带十六分之一的系数；每增加一级
+// 系数减为四分之一，使用的比例翻倍，四级时为一半。跳过边缘系数时按剩余的
+// 系数计算，多个子带时成倍增加。
+// SelectTextured时这只是上限
+func (d *DWTSteganography) capacityBits(bounds image.Rectangle) int {
+	capacity := (bounds.Dx() * bounds.Dy()) / 64 >> (d.levels() - 1)
+	if d.Wavelet != WaveletHaar {
+		rows, cols := d.regionCells(layout{region: bounds})
+		capacity = min(capacity, rows*cols<<d.levels()/32)
+	}
+	return capacity * len(d.subbandIndexes())
+}
+
+func (d *DWTSteganography) embedBits(img image.Image, bits []int, l layout) (image.Image, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
 	bounds := img.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
 
-	// 准备图像数据
-	imgData := make([][]float64, height)
-	for i := range imgData {
-		imgData[i] = make([]float64, width)
-		for j := 0; j < width; j++ {
-			r, _, _, _ := img.At(j, i).RGBA()
-			imgData[i][j] = float64(r >> 8)
-		}
+	// 确保宽高均为偶数
+	if err := d.Constraints().Check(bounds); err != nil {
+		return nil, err
+	}
+
+	if len(bits) > d.capacityBits(l.region) {
+		return nil, ErrTooLarge
 	}
 
+	// 拆分为YCbCr平面，只在亮度上嵌入，保留色度和透明度
+	planes := splitPlanes(img)
+
 	// DWT变换
-	_, _, hl, _ := d.dwt2D(imgData)
-
-	// 从HL子带提取信息
-	var bits []int
-	for i := 0; i < len(hl); i++ {
-		for j := 0; j < len(hl[0]); j++ {
-			if hl[i][j] > 0 {
-				bits = append(bits, 1)
-			} else {
-				bits = append(bits, 0)
-			}
+	p := d.decompose(lumaMatrix(planes, width, height))
 
-			// 检查结束标记
-			if len(bits)%8 == 0 {
-				text := bitsToText(bits)
-				if len(text) > 0 && text[len(text)-1] == 0 {
-					return text[:len(text)-1], nil
-				}
-			}
+	// 在选定的子带中嵌入信息，只使用对应像素完全落在原始区域内的系数
+	g := d.grid(p, l)
+	cells, skipped := d.selectCells(g, l, len(bits))
+	if len(cells) < len(bits) {
+		return nil, ErrTooLarge
+	}
+	var dither []float64
+	if d.QIMStep > 0 {
+		dither = qimDither(l.key, len(bits), d.qimStep())
+	}
+	targets := make([]dwtTarget, 0, len(cells)+len(skipped))
+	for k, cell := range cells {
+		c := g.at(cell)
+		if d.QIMStep > 0 {
+			*c = d.qimTarget(*c, dither[k], bits[k])
+		} else if bits[k] == 1 {
+			*c = math.Abs(*c) + dwtSignOffset
+		} else {
+			*c = -math.Abs(*c) - dwtSignOffset
 		}
+		targets = append(targets, dwtTarget{cell: cell, value: *c, data: true})
 	}
+	// 被跳过的系数压到阈值以下留出余量，避免取整后越过阈值被提取方选中
+	floor := d.threshold() - d.guard()
+	for _, cell := range skipped {
+		c := g.at(cell)
+		if math.Abs(*c) > floor {
+			*c = math.Copysign(floor, *c)
+		}
+		targets = append(targets, dwtTarget{cell: cell, value: *c})
+	}
+
+	// 逆变换，写回亮度
+	setLumaMatrix(planes, d.reconstruct(p))
+	d.correctCoefficients(planes, p, g, targets, l)
+
+	return planes.toImage(), nil
+}
+
+// 系数c按QIM嵌入bit后的值。SelectTextured时目标值的幅值须高出阈值留出余量，
+// 不够时沿c的符号方向移到同一比特的下一个格点（相隔两个步长）
+func (d *DWTSteganography) qimTarget(c, dither float64, bit int) float64 {
+	step := d.qimStep()
+	target := qimEmbed(c-dither, step, bit) + dither
+	if d.Selection == SelectTextured {
+		for math.Abs(target) < d.threshold()+d.guard() {
+			target += math.Copysign(2*step, c)
+		}
+	}
+	return target
+}
+
+// SelectTextured时系数与阈值之间保留的余量
+func (d *DWTSteganography) guard() float64 {
+	return d.threshold() / 4
+}
 
-	return bitsToText(bits), nil
+// dwtTarget 是嵌入后需要校验的系数
+type dwtTarget struct {
+	cell  int
+	value float64
+	// data 为false时是被跳过的系数，只需保持在阈值以下
+	data bool
 }
 
-// 辅助函数
-func isPowerOfTwo(n int) bool {
-	return n > 0 && (n&(n-1)) == 0
+// 校验嵌入结果：转换为8位RGB时的取整和截断（尤其是接近0或255的区域）
+// 会使系数偏离目标值。偏差过大的系数按偏差反向补偿后重新逆变换
+func (d *DWTSteganography) correctCoefficients(planes *colorPlanes, p *dwtPyramid, g *dwtGrid, targets []dwtTarget, l layout) {
+	passes := maxCorrectionPasses
+	if d.Selection == SelectTextured {
+		passes = dwtTexturedCorrectionPasses
+	}
+	for pass := 0; pass < passes; pass++ {
+		actual := d.grid(d.decompose(lumaMatrix(lumaPlane(planes.toImage()), planes.bounds.Dx(), planes.bounds.Dy())), l)
+		done := true
+		for _, t := range targets {
+			a := *actual.at(t.cell)
+			if d.deviates(t, a) {
+				*g.at(t.cell) += t.value - a
+				done = false
+			}
+		}
+		if done {
+			return
+		}
+		setLumaMatrix(planes, d.reconstruct(p))
+	}
+}
+
+// QIM模式下偏离目标格点四分之一步长即需补偿；符号模式下只要求符号正确、
+// 幅值不小于偏移量的一半，强边缘处截断后的幅值通常仍然足够大。
+// SelectTextured时系数还须与阈值保持一半的余量
+func (d *DWTSteganography) deviates(t dwtTarget, actual float64) bool {
+	if d.Selection == SelectTextured {
+		if !t.data {
+			return math.Abs(actual) > d.threshold()-d.guard()/2
+		}
+		if math.Abs(actual) < d.threshold()+d.guard()/2 {
+			return true
+		}
+	}
+	if d.QIMStep > 0 {
+		return math.Abs(t.value-actual) >= d.qimStep()/4
+	}
+	return t.value*actual <= 0 || math.Abs(actual) < dwtSignOffset/2
 }
 
-func textToBits(text string) []int {
-	var bits []int
-	for _, b := range []byte(text) {
-		for i := 7; i >= 0; i-- {
-			bits = append(bits, int((b>>uint(i))&1))
+// 亮度平面转换为按行存储的矩阵
+func lumaMatrix(planes *colorPlanes, width, height int) [][]float64 {
+	m := make([][]float64, height)
+	for y := range m {
+		m[y] = make([]float64, width)
+		for x := 0; x < width; x++ {
+			m[y][x] = planes.luma(x, y)
 		}
 	}
-	// 添加结束标记
-	for i := 7; i >= 0; i-- {
-		bits = append(bits, 0)
+	return m
+}
+
+// 将矩阵截断到[0,255]后写回亮度平面
+func setLumaMatrix(planes *colorPlanes, m [][]float64) {
+	for y, row := range m {
+		for x, v := range row {
+			planes.setLuma(x, y, math.Max(0, math.Min(255, v)))
+		}
 	}
-	return bits
 }
 
-func bitsToText(bits []int) string {
-	var bytes []byte
-	for i := 0; i < len(bits); i += 8 {
-		if i+8 > len(bits) {
-			break
+func (d *DWTSteganography) extractBits(img image.Image, n int, l layout) ([]int, error) {
+	bits, _, err := d.extractSoft(img, n, l)
+	return bits, err
+}
+
+// 读取前n个比特及各自的判决置信度。QIM模式下置信度由系数到判决边界的距离
+// 决定；符号模式下为系数幅值与嵌入偏移量之比，超过偏移量时为1
+func (d *DWTSteganography) extractSoft(img image.Image, n int, l layout) ([]int, []float64, error) {
+	if err := d.validate(); err != nil {
+		return nil, nil, err
+	}
+	if n > d.capacityBits(l.region) {
+		return nil, nil, fmt.Errorf("图像太小，无法读取%d位数据", n)
+	}
+	// extractLayout已按最大级数填充，直接调用时图像可能不满足当前级数的要求，
+	// 此时继续填充。使用的系数完全落在原始区域内，填充的内容不影响结果
+	if bounds := img.Bounds(); d.Constraints().Check(bounds) != nil {
+		width, height := d.Constraints().PadSize(bounds.Dx(), bounds.Dy())
+		img = padImage(img, width, height, PadEdge)
+	}
+
+	// DWT变换
+	g := d.grid(d.analyze(img), l)
+
+	// 从选定的子带提取信息
+	cells, _ := d.selectCells(g, l, n)
+	if len(cells) < n {
+		return nil, nil, fmt.Errorf("%w: 幅值超过阈值的系数不足%d个", ErrNoHiddenData, n)
+	}
+	var dither []float64
+	if d.QIMStep > 0 {
+		dither = qimDither(l.key, n, d.qimStep())
+	}
+	bits := make([]int, 0, n)
+	confidence := make([]float64, 0, n)
+	for k, cell := range cells {
+		c := *g.at(cell)
+		if d.QIMStep > 0 {
+			bit, conf := qimSoft(c-dither[k], d.qimStep())
+			bits = append(bits, bit)
+			confidence = append(confidence, conf)
+			continue
 		}
-		var b byte
-		for j := 0; j < 8; j++ {
-			b = b<<1 | byte(bits[i+j])
+		if c > 0 {
+			bits = append(bits, 1)
+		} else {
+			bits = append(bits, 0)
 		}
-		bytes = append(bytes, b)
+		confidence = append(confidence, math.Min(math.Abs(c)/dwtSignOffset, 1))
+	}
+
+	return bits, confidence, nil
+}
+
+// 辅助方法：最后一级HL子带中每个系数对应2^级数见方的像素，
+// 返回完全落在原始区域内、可以使用的系数行列数
+func (d *DWTSteganography) regionCells(l layout) (int, int) {
+	rows, cols := l.region.Dy()>>d.levels(), l.region.Dx()>>d.levels()
+	if m := d.boundaryMargin(); m > 0 {
+		rows, cols = max(rows-2*m, 0), max(cols-2*m, 0)
+	}
+	return rows, cols
+}
+
+// 可用系数距子带边缘的行列数
+func (d *DWTSteganography) boundaryMargin() int {
+	if d.Wavelet == WaveletHaar {
+		return 0
 	}
-	return string(bytes)
+	return dwtBoundaryCells
 }
 
 // 2D 逆DWT变换
@@ -1,10 +1,14 @@
 package steganography
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
 	"image"
 	"image/color"
+	"image/draw"
 	"math"
+	"math/rand/v2"
+	"strings"
 	"testing"
 )
 
@@ -21,7 +25,7 @@ func TestDWTSteganography_EmbedAndExtract(t *testing.T) {
 		{
 			name:    "基本ASCII文本",
 			text:    "Hello, World!",
-			imgSize: 256, // 必须是2的幂
+			imgSize: 256, // 宽高必须是偶数
 			wantErr: false,
 		},
 		{
@@ -45,7 +49,7 @@ func TestDWTSteganography_EmbedAndExtract(t *testing.T) {
 		{
 			name:    "非法图像尺寸",
 			text:    "test",
-			imgSize: 100, // 不是2的幂
+			imgSize: 101, // 奇数尺寸
 			wantErr: true,
 		},
 	}
@@ -95,6 +99,47 @@ func TestDWTSteganography_EmbedAndExtract(t *testing.T) {
 	}
 }
 
+func TestDWTSteganography_ArbitrarySizes(t *testing.T) {
+	dwt := NewDWTSteganography()
+	text := "任意偶数尺寸"
+
+	testCases := []struct {
+		name   string
+		bounds image.Rectangle
+	}{
+		{"非正方形", image.Rect(0, 0, 320, 96)},
+		{"非2的幂", image.Rect(0, 0, 200, 150)},
+		{"原点不为零", image.Rect(37, 11, 37+160, 11+120)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewRGBA(tc.bounds)
+			for y := tc.bounds.Min.Y; y < tc.bounds.Max.Y; y++ {
+				for x := tc.bounds.Min.X; x < tc.bounds.Max.X; x++ {
+					img.Set(x, y, color.RGBA{uint8(x * 3), uint8(y * 5), uint8(x + y), 255})
+				}
+			}
+
+			encodedImg, err := dwt.EmbedText(img, text)
+			if err != nil {
+				t.Fatalf("EmbedText() error = %v", err)
+			}
+			if encodedImg.Bounds() != tc.bounds {
+				t.Errorf("EmbedText() bounds = %v; want %v", encodedImg.Bounds(), tc.bounds)
+			}
+
+			extracted, err := dwt.ExtractText(encodedImg)
+			if err != nil {
+				t.Fatalf("ExtractText() error = %v", err)
+			}
+			if extracted != text {
+				t.Errorf("ExtractText() = %q; want %q", extracted, text)
+			}
+		})
+	}
+}
+
 func TestDWT_Transform(t *testing.T) {
 	dwt := NewDWTSteganography()
 
@@ -155,30 +200,377 @@ func TestDWT_Transform(t *testing.T) {
 	})
 }
 
-func TestIsPowerOfTwo(t *testing.T) {
-	testCases := []struct {
-		input    int
-		expected bool
-	}{
-		{0, false},
-		{1, true},
-		{2, true},
-		{3, false},
-		{4, true},
-		{8, true},
-		{16, true},
-		{100, false},
-		{256, true},
-		{1024, true},
+// 每个通道加上[-amount, amount]内的均匀噪声
+func addNoise(img image.Image, amount int, seed uint64) *image.RGBA {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	bounds := img.Bounds()
+	noisy := image.NewRGBA(bounds)
+	jitter := func(v uint32) uint8 {
+		return uint8(max(0, min(255, int(v>>8)+rng.IntN(2*amount+1)-amount)))
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			noisy.Set(x, y, color.RGBA{jitter(r), jitter(g), jitter(b), uint8(a >> 8)})
+		}
+	}
+	return noisy
+}
+
+// 两幅图像RGB通道的均方误差
+func meanSquaredError(a, b image.Image) float64 {
+	bounds := a.Bounds()
+	var sum float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			for _, d := range []float64{float64(r1>>8) - float64(r2>>8), float64(g1>>8) - float64(g2>>8), float64(b1>>8) - float64(b2>>8)} {
+				sum += d * d
+			}
+		}
 	}
+	return sum / float64(bounds.Dx()*bounds.Dy()*3)
+}
 
-	for _, tc := range testCases {
-		t.Run(fmt.Sprintf("input_%d", tc.input), func(t *testing.T) {
-			result := isPowerOfTwo(tc.input)
-			if result != tc.expected {
-				t.Errorf("isPowerOfTwo(%d) = %v; want %v",
-					tc.input, result, tc.expected)
+func TestDWT_QIM(t *testing.T) {
+	cover := newGradientImage(256)
+	text := "dither modulation"
+
+	sign, err := NewDWTSteganography().EmbedText(cover, text)
+	if err != nil {
+		t.Fatalf("sign EmbedText() error = %v", err)
+	}
+	signMSE := meanSquaredError(cover, sign)
+
+	for _, step := range dwtQIMSteps {
+		encoded, err := NewDWTWithQIMStep(step).EmbedText(cover, text)
+		if err != nil {
+			t.Fatalf("step %d: EmbedText() error = %v", step, err)
+		}
+		// 提取时自动识别步长
+		got, err := NewDWTSteganography().ExtractText(encoded)
+		if err != nil || got != text {
+			t.Errorf("step %d: ExtractText() = %q, %v; want %q", step, got, err, text)
+		}
+		// 平坦区域不再被推到±20，小步长的失真应远低于符号嵌入
+		if mse := meanSquaredError(cover, encoded); step <= 16 && mse >= signMSE/2 {
+			t.Errorf("step %d: MSE = %.3f; sign embedding %.3f", step, mse, signMSE)
+		}
+	}
+
+	if _, err := NewDWTWithQIMStep(5).EmbedText(cover, text); err == nil {
+		t.Error("EmbedText() with unsupported step succeeded")
+	}
+}
+
+func TestDWT_Confidence(t *testing.T) {
+	cover := newGradientImage(256)
+	data := []byte("soft decision")
+	opts := &Options{Key: "key"}
+	s := NewDWTWithQIMStep(24)
+	encoded, err := Embed(s, cover, data, opts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	mean := func(conf []float64) float64 {
+		var sum float64
+		for _, c := range conf {
+			if c < 0 || c > 1 {
+				t.Fatalf("confidence %v out of range", c)
+			}
+			sum += c
+		}
+		return sum / float64(len(conf))
+	}
+
+	f, clean, err := ExtractWithConfidence(NewDWTSteganography(), encoded, opts)
+	if err != nil {
+		t.Fatalf("ExtractWithConfidence() error = %v", err)
+	}
+	if !bytes.Equal(f.Data, data) {
+		t.Errorf("ExtractWithConfidence() data = %q; want %q", f.Data, data)
+	}
+	if want := (headerSize + len(data)) * 8; len(clean) != want {
+		t.Errorf("len(confidence) = %d; want %d", len(clean), want)
+	}
+
+	// 噪声使系数偏离格点，数据仍可提取但置信度下降
+	f, noisy, err := ExtractWithConfidence(s, addNoise(encoded, 3, 1), opts)
+	if err != nil {
+		t.Fatalf("ExtractWithConfidence() after noise error = %v", err)
+	}
+	if !bytes.Equal(f.Data, data) {
+		t.Errorf("ExtractWithConfidence() after noise data = %q; want %q", f.Data, data)
+	}
+	if mean(noisy) >= mean(clean) {
+		t.Errorf("mean confidence after noise = %.3f; clean %.3f", mean(noisy), mean(clean))
+	}
+
+	if _, _, err := ExtractWithConfidence(NewLSB(), encoded, opts); err == nil {
+		t.Error("ExtractWithConfidence() with LSB succeeded")
+	}
+}
+
+func TestWavelet_Reconstruct(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	random := make([]float64, 32)
+	linear := make([]float64, 32)
+	for i := range random {
+		random[i] = rng.Float64() * 255
+		linear[i] = float64(3*i + 7)
+	}
+	energy := func(data ...[]float64) float64 {
+		var sum float64
+		for _, d := range data {
+			for _, v := range d {
+				sum += v * v
+			}
+		}
+		return sum
+	}
+
+	for w := Wavelet(0); w < waveletCount; w++ {
+		t.Run(w.String(), func(t *testing.T) {
+			approx, detail := w.forward(random)
+			for i, v := range w.inverse(approx, detail) {
+				if math.Abs(v-random[i]) > 1e-9 {
+					t.Fatalf("inverse()[%d] = %f; want %f", i, v, random[i])
+				}
+			}
+			// 正交小波保持能量
+			if w != WaveletCDF97 && math.Abs(energy(approx, detail)-energy(random)) > 1e-6 {
+				t.Errorf("energy = %f; want %f", energy(approx, detail), energy(random))
+			}
+
+			// Daubechies-4和CDF 9/7对线性信号的细节系数为0，只在边界处可能不为0
+			_, detail = w.forward(linear)
+			for i := 1; i < len(detail)-2; i++ {
+				if w != WaveletHaar && math.Abs(detail[i]) > 1e-9 {
+					t.Errorf("detail[%d] of linear signal = %f; want 0", i, detail[i])
+				}
 			}
 		})
 	}
 }
+
+func TestDWT_Wavelets(t *testing.T) {
+	cover := newGradientImage(512)
+	text := "wavelets"
+
+	for w := Wavelet(0); w < waveletCount; w++ {
+		for levels := 1; levels <= dwtMaxLevels; levels++ {
+			s := NewDWTWithWavelet(w, levels)
+			if levels == 2 {
+				s.QIMStep = 16
+			}
+			encoded, err := s.EmbedText(cover, text)
+			if err != nil {
+				t.Fatalf("%v/%d: EmbedText() error = %v", w, levels, err)
+			}
+			// 提取时按默认配置读取，自动识别小波和级数
+			got, err := NewDWTSteganography().ExtractText(encoded)
+			if err != nil || got != text {
+				t.Errorf("%v/%d: ExtractText() = %q, %v; want %q", w, levels, got, err, text)
+			}
+			if levels != dwtMaxLevels {
+				continue
+			}
+			name, header, err := Inspect(encoded, nil)
+			if err != nil || name != "DWT" {
+				t.Fatalf("%v/%d: Inspect() = %q, %v", w, levels, name, err)
+			}
+			if header.Params != s.params() {
+				t.Errorf("%v/%d: params = %#x; want %#x", w, levels, header.Params, s.params())
+			}
+		}
+	}
+
+	// 平坦区域中深层系数的可调粒度较大，QIM步长随级数放大后仍可提取
+	grey := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	draw.Draw(grey, grey.Bounds(), image.NewUniform(color.RGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+	for levels := 1; levels <= dwtMaxLevels; levels++ {
+		s := &DWTSteganography{QIMStep: 16, Levels: levels}
+		encoded, err := s.EmbedText(grey, text)
+		if err != nil {
+			t.Fatalf("flat/%d: EmbedText() error = %v", levels, err)
+		}
+		if got, err := s.ExtractText(encoded); err != nil || got != text {
+			t.Errorf("flat/%d: ExtractText() = %q, %v; want %q", levels, got, err, text)
+		}
+	}
+
+	// 图像尺寸须为2^级数的倍数，否则需要填充。提取方按默认级数填充，
+	// 边缘处的系数取决于填充内容，不能使用
+	odd := newGradientImage(395)
+	if _, err := NewDWTWithWavelet(WaveletCDF97, 3).EmbedText(odd, text); err == nil {
+		t.Error("EmbedText() with 395x395 image and 3 levels succeeded")
+	}
+	opts := &Options{Pad: PadMirror, Key: "pad"}
+	for w := Wavelet(0); w < waveletCount; w++ {
+		encoded, err := Embed(NewDWTWithWavelet(w, 3), odd, []byte(text), opts)
+		if err != nil {
+			t.Fatalf("%v: Embed() with padding error = %v", w, err)
+		}
+		got, err := Extract(NewDWTSteganography(), encoded, opts)
+		if err != nil || string(got) != text {
+			t.Errorf("%v: Extract() of padded image = %q, %v; want %q", w, got, err, text)
+		}
+	}
+	if _, err := NewDWTWithWavelet(WaveletHaar, dwtMaxLevels+1).EmbedText(cover, text); err == nil {
+		t.Errorf("EmbedText() with %d levels succeeded", dwtMaxLevels+1)
+	}
+}
+
+func TestParseWavelet(t *testing.T) {
+	for w := Wavelet(0); w < waveletCount; w++ {
+		if got, err := ParseWavelet(strings.ToUpper(w.String())); err != nil || got != w {
+			t.Errorf("ParseWavelet(%q) = %v, %v; want %v", w.String(), got, err, w)
+		}
+	}
+	if _, err := ParseWavelet("db8"); err == nil {
+		t.Error("ParseWavelet(\"db8\") succeeded")
+	}
+}
+
+func TestDWT_Subbands(t *testing.T) {
+	cover := newGradientImage(256)
+	text := "subbands"
+	base := NewDWTSteganography().Capacity(cover.Bounds())
+
+	for _, subbands := range []Subband{SubbandLH, SubbandHH, SubbandLH | SubbandHH, subbandAll} {
+		s := &DWTSteganography{Subbands: subbands}
+		encoded, err := s.EmbedText(cover, text)
+		if err != nil {
+			t.Fatalf("%v: EmbedText() error = %v", subbands, err)
+		}
+		if got, err := NewDWTSteganography().ExtractText(encoded); err != nil || got != text {
+			t.Errorf("%v: ExtractText() = %q, %v; want %q", subbands, got, err, text)
+		}
+	}
+
+	// 负载头写在HL子带的固定位置，子带从负载头得到，不必枚举
+	for _, v := range NewDWTSteganography().variants() {
+		if v.params()&dwtSubbandMask != 0 {
+			t.Errorf("variants() includes subbands %#x", v.params())
+		}
+	}
+	opts := &Options{Key: "subbands", ECC: ECCLow}
+	s := &DWTSteganography{QIMStep: 8, Subbands: SubbandLH | SubbandHH}
+	encoded, err := Embed(s, cover, []byte(text), opts)
+	if err != nil {
+		t.Fatalf("Embed() with ECC error = %v", err)
+	}
+	f, confidence, err := ExtractWithConfidence(&DWTSteganography{Wavelet: WaveletCDF97}, encoded, opts)
+	if err != nil || string(f.Data) != text || len(confidence) == 0 {
+		t.Errorf("ExtractWithConfidence() = %v, %d, %v; want %q", f, len(confidence), err, text)
+	}
+
+	// 三个子带的容量约为只用HL时的三倍
+	if got := (&DWTSteganography{Subbands: subbandAll}).Capacity(cover.Bounds()); got < base*3 {
+		t.Errorf("Capacity() with all subbands = %d; HL only %d", got, base)
+	}
+	// 只用HL时参数与早期版本相同
+	if got, want := (&DWTSteganography{Subbands: SubbandHL}).params(), NewDWTSteganography().params(); got != want {
+		t.Errorf("params() with HL = %#x; want %#x", got, want)
+	}
+
+	if got, err := ParseSubbands("hl+HH"); err != nil || got != SubbandHL|SubbandHH || got.String() != "HL+HH" {
+		t.Errorf("ParseSubbands(\"hl+HH\") = %v, %v", got, err)
+	}
+	for _, spec := range []string{"", "LL", "hl,xx"} {
+		if _, err := ParseSubbands(spec); err == nil {
+			t.Errorf("ParseSubbands(%q) succeeded", spec)
+		}
+	}
+}
+
+// 上半部分为平坦的天空，下半部分为纹理
+func newSkyImage(size int) *image.RGBA {
+	rng := rand.New(rand.NewPCG(7, 8))
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if y < size/2 {
+				img.Set(x, y, color.RGBA{110, 160, 220, 255})
+			} else {
+				v := uint8(60 + rng.IntN(120))
+				img.Set(x, y, color.RGBA{v, v - 20, v / 2, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestDWT_TexturedSelection(t *testing.T) {
+	cover := newSkyImage(256)
+	data := []byte("hidden in the texture")
+	opts := &Options{Key: "texture"}
+
+	// 天空中亮度被修改的像素数。色彩空间往返转换本身有取整误差，只统计超过1的改动；
+	// 较长的小波在纹理边缘的改动会延伸到附近几行，只检查离纹理较远的部分
+	skyChanges := func(encoded image.Image) int {
+		before, after := lumaPlane(cover), lumaPlane(encoded)
+		changed := 0
+		for y := 0; y < 96; y++ {
+			for x := 0; x < 256; x++ {
+				if math.Abs(after.luma(x, y)-before.luma(x, y)) > 1 {
+					changed++
+				}
+			}
+		}
+		return changed
+	}
+
+	for _, s := range []*DWTSteganography{
+		{Selection: SelectTextured},
+		{Selection: SelectTextured, QIMStep: 8, Subbands: SubbandHL | SubbandHH},
+		{Selection: SelectTextured, Wavelet: WaveletCDF97, Levels: 2},
+	} {
+		encoded, err := Embed(s, cover, data, opts)
+		if err != nil {
+			t.Fatalf("%+v: Embed() error = %v", s, err)
+		}
+		got, err := Extract(NewDWTSteganography(), encoded, opts)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%+v: Extract() = %q, %v; want %q", s, got, err, data)
+		}
+		if n := skyChanges(encoded); n != 0 {
+			t.Errorf("%+v: %d sky pixels changed", s, n)
+		}
+	}
+
+	// 不跳过平坦区域时天空也会被修改
+	encoded, err := Embed(NewDWTSteganography(), cover, data, opts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if skyChanges(encoded) == 0 {
+		t.Error("SelectAll left the sky untouched")
+	}
+
+	// 纯色图像没有可用的系数
+	flat := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	if _, err := Embed(&DWTSteganography{Selection: SelectTextured}, flat, data, nil); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Embed() into flat image error = %v; want ErrTooLarge", err)
+	}
+}
+
+// 用默认配置提取按其他小波和级数嵌入的数据，需要枚举参数组合。
+// 宽高不是16的倍数，各级数都需要填充
+func BenchmarkDWT_ExtractVariants(b *testing.B) {
+	cover := addNoise(newGradientImage(1000), 20, 5)
+	cover = cover.SubImage(image.Rect(0, 0, 1000, 750)).(*image.RGBA)
+	d := &DWTSteganography{QIMStep: 16, Wavelet: WaveletCDF97, Levels: 3}
+	stego, err := Embed(d, cover, []byte("benchmark"), &Options{Pad: PadEdge})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if got, err := Extract(NewDWTSteganography(), stego, nil); err != nil || string(got) != "benchmark" {
+			b.Fatalf("Extract() = %q, %v", got, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package steganography
+
+import "fmt"
+
+// ECCLevel 是Reed–Solomon纠错的冗余等级。负载按255字节一个码字分块编码，
+// 每个码字最多可纠正校验字节数一半的字节错误
+type ECCLevel uint8
+
+const (
+	// ECCNone 不使用纠错
+	ECCNone ECCLevel = iota
+	// ECCLow 每个码字16字节校验，可纠正约3%的字节错误
+	ECCLow
+	// ECCMedium 每个码字32字节校验，可纠正约6%的字节错误
+	ECCMedium
+	// ECCHigh 每个码字64字节校验，可纠正约12%的字节错误
+	ECCHigh
+)
+
+// 纠错帧负载头的校验字节数，与负载的纠错等级无关
+const (
+	eccHeaderParity    = 16
+	eccHeaderCodeword  = eccHeaderSize + eccHeaderParity
+	rsMaxCodewordBytes = 255
+)
+
+func (e ECCLevel) String() string {
+	switch e {
+	case ECCNone:
+		return "none"
+	case ECCLow:
+		return "low"
+	case ECCMedium:
+		return "medium"
+	case ECCHigh:
+		return "high"
+	}
+	return fmt.Sprintf("ECCLevel(%d)", uint8(e))
+}
+
+// 每个码字的校验字节数，未知等级返回0
+func (e ECCLevel) parity() int {
+	switch e {
+	case ECCLow:
+		return 16
+	case ECCMedium:
+		return 32
+	case ECCHigh:
+		return 64
+	}
+	return 0
+}
+
+// 编码后的负载长度
+func eccEncodedLen(n, nsym int) int {
+	k := rsMaxCodewordBytes - nsym
+	return n + (n+k-1)/k*nsym
+}
+
+// 将负载按码字分块编码
+func eccEncode(payload []byte, nsym int) []byte {
+	k := rsMaxCodewordBytes - nsym
+	out := make([]byte, 0, eccEncodedLen(len(payload), nsym))
+	for start := 0; start < len(payload); start += k {
+		end := min(start+k, len(payload))
+		out = append(out, rsEncode(payload[start:end], nsym)...)
+	}
+	return out
+}
+
+// 逐个码字纠错，返回长度为n的负载及纠正的字节数
+func eccDecode(encoded []byte, n, nsym int) ([]byte, int, error) {
+	k := rsMaxCodewordBytes - nsym
+	payload := make([]byte, 0, n)
+	corrected := 0
+	for start := 0; start < n; start += k {
+		chunk := min(k, n-start)
+		offset := start / k * rsMaxCodewordBytes
+		msg, fixed, err := rsDecode(encoded[offset:offset+chunk+nsym], nsym)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: 第%d个码字%v", ErrCorrupted, start/k+1, err)
+		}
+		payload = append(payload, msg...)
+		corrected += fixed
+	}
+	return payload, corrected, nil
+}
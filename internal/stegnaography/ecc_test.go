@@ -0,0 +1,109 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+)
+
+// 按字节翻转已嵌入帧中的部分比特，模拟传输中的误码。
+// damage返回需要翻转的帧内字节下标
+func corruptFrame(t *testing.T, s Steganographer, img image.Image, frameLen int, damage func(i int) bool) image.Image {
+	t.Helper()
+	c := s.(carrier)
+	l := layout{region: img.Bounds()}
+	bits, err := c.extractBits(img, frameLen*8, l)
+	if err != nil {
+		t.Fatalf("extractBits() error = %v", err)
+	}
+	for i := 0; i < frameLen; i++ {
+		if damage(i) {
+			for j := 0; j < 8; j += 3 {
+				bits[i*8+j] ^= 1
+			}
+		}
+	}
+	damaged, err := c.embedBits(img, bits, l)
+	if err != nil {
+		t.Fatalf("embedBits() error = %v", err)
+	}
+	return damaged
+}
+
+func TestECC_CorrectsErrors(t *testing.T) {
+	data := bytes.Repeat([]byte("forward error correction "), 12)
+	opts := &Options{ECC: ECCMedium}
+	frameLen := headerSize + PayloadSize(data, opts)
+
+	for _, s := range []Steganographer{NewLSB(), NewDCTSteganography()} {
+		t.Run(s.Name(), func(t *testing.T) {
+			encoded, err := Embed(s, newGradientImage(512), data, opts)
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+
+			// 负载头码字和每个负载码字中的错误都在纠错能力之内
+			damaged := corruptFrame(t, s, encoded, frameLen, func(i int) bool { return i%20 == 5 })
+
+			f, err := ExtractFile(s, damaged, opts)
+			if err != nil {
+				t.Fatalf("ExtractFile() error = %v", err)
+			}
+			if !bytes.Equal(f.Data, data) {
+				t.Errorf("ExtractFile() data mismatch")
+			}
+			if want := (frameLen + 14) / 20; f.Corrected != want {
+				t.Errorf("Corrected = %d; want %d", f.Corrected, want)
+			}
+
+			_, header, err := Inspect(damaged, opts)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if header.Version != headerVersionECC || header.ECC != ECCMedium {
+				t.Errorf("Inspect() version = %d, ECC = %v; want %d, %v", header.Version, header.ECC, headerVersionECC, ECCMedium)
+			}
+		})
+	}
+}
+
+func TestECC_TooManyErrors(t *testing.T) {
+	data := bytes.Repeat([]byte{0x42}, 300)
+	opts := &Options{ECC: ECCLow}
+	s := NewLSB()
+	encoded, err := Embed(s, newGradientImage(128), data, opts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	// 只破坏负载，负载头可以纠正
+	frameLen := headerSize + PayloadSize(data, opts)
+	damaged := corruptFrame(t, s, encoded, frameLen, func(i int) bool {
+		return i >= eccHeaderCodeword && i%4 == 0
+	})
+	if _, err := Extract(s, damaged, opts); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Extract() error = %v; want ErrCorrupted", err)
+	}
+}
+
+func TestECC_PayloadSize(t *testing.T) {
+	data := make([]byte, 1000)
+	for _, level := range []ECCLevel{ECCLow, ECCMedium, ECCHigh} {
+		opts := &Options{ECC: level}
+		s := NewLSB()
+		bounds := image.Rect(0, 0, 64, 64)
+
+		// PayloadSize 与 Capacity 比较的结果应与实际嵌入一致
+		fits := PayloadSize(data, opts) <= s.Capacity(bounds)
+		_, err := Embed(s, image.NewRGBA(bounds), data, opts)
+		if fits != (err == nil) {
+			t.Errorf("%v: PayloadSize() = %d, Capacity() = %d, Embed() error = %v",
+				level, PayloadSize(data, opts), s.Capacity(bounds), err)
+		}
+	}
+
+	if _, err := Embed(NewLSB(), newGradientImage(64), data, &Options{ECC: ECCLevel(9)}); err == nil {
+		t.Error("Embed() with unknown ECC level succeeded")
+	}
+}
@@ -0,0 +1,290 @@
+package steganography
+
+import (
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/color"
+	"slices"
+
+	"steganography-tool/internal/jpegcoef"
+)
+
+// F5（Westfeld, 2001）直接修改JPEG的量化系数，不经过像素域，输出的JPEG
+// 与原图量化表相同、大小相近。
+//
+// 系数的比特值：正数取最低位，负数取最低位的反；修改时总是让幅值减1。
+// 只使用非零的AC系数，按密钥播种的顺序遍历。每2^k-1个非零系数组成一组，
+// 以矩阵编码嵌入k比特：各系数的比特值为1时将其序号（从1开始）异或起来，
+// 结果与待嵌入的k比特不同时修改序号等于两者异或值的系数，每组最多修改一个
+// 系数。幅值为1的系数修改后变为0（收缩），提取时会被跳过，此时去掉该系数
+// 补充下一个后重新嵌入同一组。
+//
+// 最前面的f5StatusBits比特以k=1嵌入，记录之后使用的k
+const (
+	f5StatusBits = 4
+	f5MaxK       = 1<<f5StatusBits - 1
+)
+
+// f5Carrier 实现carrier接口。载体是JPEG系数而不是像素，接口中的图像只是
+// 提供尺寸的占位，比特直接写入coef
+type f5Carrier struct {
+	coef *jpegcoef.Image
+	// 各分量有效区域内的块，系数位置slot对应blocks[slot/63]的第slot%63+1个系数
+	blocks []*jpegcoef.Block
+
+	// 缓存的遍历顺序，同一次嵌入或提取中会多次读取
+	order    []int
+	orderKey string
+}
+
+func newF5Carrier(coef *jpegcoef.Image) *f5Carrier {
+	f := &f5Carrier{coef: coef}
+	for _, c := range coef.Components {
+		for by := 0; by < c.BlocksHigh; by++ {
+			for bx := 0; bx < c.BlocksWide; bx++ {
+				f.blocks = append(f.blocks, c.Block(bx, by))
+			}
+		}
+	}
+	return f
+}
+
+// 占位图像及对应的布局，F5不需要填充
+func (f *f5Carrier) placeholder(opts *Options) (image.Image, layout) {
+	bounds := image.Rect(0, 0, f.coef.Width, f.coef.Height)
+	return boundsImage(bounds), layout{key: opts.key(), region: bounds}
+}
+
+func (f *f5Carrier) Constraints() Constraints {
+	return Constraints{}
+}
+
+func (f *f5Carrier) algorithmID() uint8 {
+	return AlgorithmF5
+}
+
+func (f *f5Carrier) params() uint32 {
+	return 0
+}
+
+// 不考虑收缩时的上限：k=1时每个非零系数存储1位
+func (f *f5Carrier) capacityBits(image.Rectangle) int {
+	large, ones := f.count()
+	return max(large+ones-f5StatusBits, 0)
+}
+
+// 预计可嵌入的比特数。按F5的估计，幅值为1的系数约有一半会因收缩而浪费
+func (f *f5Carrier) expectedBits() int {
+	large, ones := f.count()
+	return max(large+ones*49/100-f5StatusBits, 0)
+}
+
+// 统计幅值大于1和等于1的AC系数个数
+func (f *f5Carrier) count() (large, ones int) {
+	for _, b := range f.blocks {
+		for _, c := range b[1:] {
+			switch {
+			case c == 1 || c == -1:
+				ones++
+			case c != 0:
+				large++
+			}
+		}
+	}
+	return large, ones
+}
+
+func (f *f5Carrier) slotOrder(key string) []int {
+	if f.order == nil || f.orderKey != key {
+		total := len(f.blocks) * 63
+		f.order = shuffledOrder(sha256.Sum256([]byte(f5OrderDomain+key)), total, total)
+		f.orderKey = key
+	}
+	return f.order
+}
+
+// 选择能放下n比特的最大k。k越大每比特需要的修改越少，但占用的系数越多
+func (f *f5Carrier) chooseK(n int) int {
+	expected := f.expectedBits()
+	for k := f5MaxK; k > 1; k-- {
+		if (n+k-1)/k*(1<<k-1) <= expected {
+			return k
+		}
+	}
+	return 1
+}
+
+func (f *f5Carrier) embedBits(img image.Image, bits []int, l layout) (image.Image, error) {
+	order := f.slotOrder(l.key)
+	// 收缩的比例只能估计，按估计的k放不下时改用较小的k重试
+	for k := f.chooseK(len(bits)); k >= 1; k-- {
+		err := f.embedWithK(order, bits, k)
+		if err == nil {
+			return img, nil
+		}
+		if !errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+	}
+	return nil, ErrTooLarge
+}
+
+// 以参数k嵌入全部比特，系数不足时撤销已做的修改
+func (f *f5Carrier) embedWithK(order, bits []int, k int) error {
+	s := &f5Stream{f: f, order: order}
+	embed := func() error {
+		for i := f5StatusBits - 1; i >= 0; i-- {
+			if err := s.embedGroup(k>>i&1, 1); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < len(bits); i += k {
+			// 最后一组不足k比特时补0
+			value := 0
+			for j := i; j < i+k; j++ {
+				value <<= 1
+				if j < len(bits) {
+					value |= bits[j] & 1
+				}
+			}
+			if err := s.embedGroup(value, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := embed(); err != nil {
+		s.undo()
+		return err
+	}
+	return nil
+}
+
+func (f *f5Carrier) extractBits(img image.Image, n int, l layout) ([]int, error) {
+	s := &f5Stream{f: f, order: f.slotOrder(l.key)}
+	k := 0
+	for i := 0; i < f5StatusBits; i++ {
+		bit, ok := s.extractGroup(1)
+		if !ok {
+			return nil, ErrNoHiddenData
+		}
+		k = k<<1 | bit
+	}
+	if k == 0 {
+		return nil, ErrNoHiddenData
+	}
+
+	bits := make([]int, 0, n+k)
+	for len(bits) < n {
+		value, ok := s.extractGroup(k)
+		if !ok {
+			return nil, ErrNoHiddenData
+		}
+		for j := k - 1; j >= 0; j-- {
+			bits = append(bits, value>>j&1)
+		}
+	}
+	return bits[:n], nil
+}
+
+// f5Stream 按遍历顺序依次取出非零系数，并记录修改以便撤销
+type f5Stream struct {
+	f       *f5Carrier
+	order   []int
+	pos     int
+	changes []f5Change
+}
+
+type f5Change struct {
+	coef *int32
+	old  int32
+}
+
+func (s *f5Stream) next() (*int32, bool) {
+	for s.pos < len(s.order) {
+		slot := s.order[s.pos]
+		s.pos++
+		c := &s.f.blocks[slot/63][slot%63+1]
+		if *c != 0 {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// 用一组2^k-1个非零系数嵌入k比特的value
+func (s *f5Stream) embedGroup(value, k int) error {
+	n := 1<<k - 1
+	group := make([]*int32, 0, n)
+	for {
+		for len(group) < n {
+			c, ok := s.next()
+			if !ok {
+				return ErrTooLarge
+			}
+			group = append(group, c)
+		}
+		i := groupHash(group) ^ value
+		if i == 0 {
+			return nil
+		}
+		c := group[i-1]
+		s.changes = append(s.changes, f5Change{c, *c})
+		if *c > 0 {
+			*c--
+		} else {
+			*c++
+		}
+		if *c != 0 {
+			return nil
+		}
+		// 收缩：该系数提取时会被跳过，去掉后补充下一个系数重新嵌入
+		group = slices.Delete(group, i-1, i)
+	}
+}
+
+// 读取一组系数中的k比特，系数不足时返回false
+func (s *f5Stream) extractGroup(k int) (int, bool) {
+	n := 1<<k - 1
+	group := make([]*int32, 0, n)
+	for len(group) < n {
+		c, ok := s.next()
+		if !ok {
+			return 0, false
+		}
+		group = append(group, c)
+	}
+	return groupHash(group), true
+}
+
+func (s *f5Stream) undo() {
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		*s.changes[i].coef = s.changes[i].old
+	}
+	s.changes = nil
+}
+
+// 比特值为1的系数序号（从1开始）的异或
+func groupHash(group []*int32) int {
+	h := 0
+	for i, c := range group {
+		h ^= (i + 1) * f5Bit(*c)
+	}
+	return h
+}
+
+// 非零系数的比特值：正数取最低位，负数取最低位的反
+func f5Bit(c int32) int {
+	if c > 0 {
+		return int(c & 1)
+	}
+	return 1 - int(c&1)
+}
+
+// boundsImage 只有尺寸的占位图像
+type boundsImage image.Rectangle
+
+func (b boundsImage) ColorModel() color.Model { return color.GrayModel }
+func (b boundsImage) Bounds() image.Rectangle { return image.Rectangle(b) }
+func (b boundsImage) At(x, y int) color.Color { return color.Gray{} }
@@ -0,0 +1,170 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand/v2"
+	"testing"
+
+	"steganography-tool/internal/jpegcoef"
+)
+
+// 带噪声的彩色图，按给定质量保存为JPEG
+func newCoverJPEG(t *testing.T, size, quality int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(5, 6))
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, color.RGBA{uint8(x/2 + rng.IntN(40)), uint8(y/2 + rng.IntN(40)), uint8((x + y) / 4), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("jpeg.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestF5_EmbedAndExtract(t *testing.T) {
+	cover := newCoverJPEG(t, 256, 80)
+	data := bytes.Repeat([]byte("F5 matrix encoding "), 20)
+
+	for _, opts := range []*Options{
+		nil,
+		{Key: "secret", Passphrase: "pass"},
+		{Key: "secret", Compress: true, ECC: ECCLow},
+	} {
+		out, err := NewF5().Embed(cover, data, opts)
+		if err != nil {
+			t.Fatalf("Embed(%+v) error = %v", opts, err)
+		}
+		// 输出是有效的JPEG，大小与原图相近
+		if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+			t.Fatalf("jpeg.Decode() error = %v", err)
+		}
+		if len(out) > len(cover)*11/10 {
+			t.Errorf("output size = %d; cover %d", len(out), len(cover))
+		}
+
+		got, err := NewF5().ExtractFile(out, opts)
+		if err != nil {
+			t.Fatalf("ExtractFile(%+v) error = %v", opts, err)
+		}
+		if !bytes.Equal(got.Data, data) {
+			t.Errorf("ExtractFile(%+v) data mismatch", opts)
+		}
+	}
+}
+
+func TestF5_File(t *testing.T) {
+	cover := newCoverJPEG(t, 128, 90)
+	f := NewFile("notes.txt", []byte("hidden in coefficients"))
+	out, err := NewF5().EmbedFile(cover, f, nil)
+	if err != nil {
+		t.Fatalf("EmbedFile() error = %v", err)
+	}
+
+	got, err := NewF5().ExtractFile(out, nil)
+	if err != nil {
+		t.Fatalf("ExtractFile() error = %v", err)
+	}
+	if got.Name != f.Name || got.ContentType != f.ContentType || !bytes.Equal(got.Data, f.Data) {
+		t.Errorf("ExtractFile() = %q %q %q", got.Name, got.ContentType, got.Data)
+	}
+
+	name, header, err := InspectJPEG(out, nil)
+	if err != nil {
+		t.Fatalf("InspectJPEG() error = %v", err)
+	}
+	if name != "F5" || header.Algorithm != AlgorithmF5 || header.Flags&FlagFile == 0 {
+		t.Errorf("InspectJPEG() = %q, algorithm = %d, flags = %#x", name, header.Algorithm, header.Flags)
+	}
+	if _, _, err := InspectJPEG(cover, nil); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("InspectJPEG() of clean cover error = %v; want ErrNoHiddenData", err)
+	}
+}
+
+func TestF5_Errors(t *testing.T) {
+	cover := newCoverJPEG(t, 128, 75)
+	f5 := NewF5()
+	out, err := f5.Embed(cover, []byte("message"), &Options{Key: "right"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	if _, err := f5.ExtractFile(out, &Options{Key: "wrong"}); err == nil {
+		t.Error("ExtractFile() with wrong key succeeded")
+	}
+	if _, err := f5.ExtractFile(cover, nil); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("ExtractFile() of clean cover error = %v; want ErrNoHiddenData", err)
+	}
+
+	capacity, err := f5.Capacity(cover)
+	if err != nil {
+		t.Fatalf("Capacity() error = %v", err)
+	}
+	_, err = f5.Embed(cover, make([]byte, capacity*2), nil)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Embed() over capacity error = %v; want ErrTooLarge", err)
+	}
+}
+
+// 矩阵编码修改的系数应少于k=1时，且只会使幅值减1
+func TestF5_MatrixEncoding(t *testing.T) {
+	cover := newCoverJPEG(t, 256, 80)
+	bits := bytesToBits([]byte("a short message for matrix encoding"))
+
+	embedWithK := func(k int) int {
+		t.Helper()
+		f, err := readF5(cover)
+		if err != nil {
+			t.Fatalf("readF5() error = %v", err)
+		}
+		before, _ := jpegcoef.Decode(bytes.NewReader(cover))
+		img, l := f.placeholder(nil)
+		if err := f.embedWithK(f.slotOrder(l.key), bits, k); err != nil {
+			t.Fatalf("k=%d: embedWithK() error = %v", k, err)
+		}
+		got, err := f.extractBits(img, len(bits), l)
+		if err != nil {
+			t.Fatalf("k=%d: extractBits() error = %v", k, err)
+		}
+		for i := range bits {
+			if got[i] != bits[i] {
+				t.Fatalf("k=%d: bit %d = %d; want %d", k, i, got[i], bits[i])
+			}
+		}
+
+		changed := 0
+		for i, c := range before.Components {
+			for by := 0; by < c.BlocksHigh; by++ {
+				for bx := 0; bx < c.BlocksWide; bx++ {
+					b, a := c.Block(bx, by), f.coef.Components[i].Block(bx, by)
+					for j := range b {
+						if b[j] == a[j] {
+							continue
+						}
+						changed++
+						if j == 0 || abs(int(a[j])) != abs(int(b[j]))-1 {
+							t.Fatalf("k=%d: coefficient %d changed from %d to %d", k, j, b[j], a[j])
+						}
+					}
+				}
+			}
+		}
+		return changed
+	}
+
+	f, _ := readF5(cover)
+	k := f.chooseK(len(bits))
+	if k <= 1 {
+		t.Fatalf("chooseK(%d) = %d; want > 1", len(bits), k)
+	}
+	if plain, matrix := embedWithK(1), embedWithK(k); matrix >= plain {
+		t.Errorf("k=%d changed %d coefficients; k=1 changed %d", k, matrix, plain)
+	}
+}
@@ -0,0 +1,151 @@
+package steganography
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 文件元数据的格式（位于加密之前，元数据同样会被加密）：
+//
+//	2字节   文件名长度
+//	n字节   文件名（UTF-8，不含目录）
+//	1字节   内容类型长度
+//	m字节   内容类型
+//	4字节   文件大小
+//	其余    文件内容
+const (
+	maxFileNameLen    = 1<<16 - 1
+	maxContentTypeLen = 1<<8 - 1
+	fileMetaSize      = 2 + 1 + 4
+)
+
+// 无法识别扩展名时使用的内容类型
+const defaultContentType = "application/octet-stream"
+
+// File 是嵌入图像的文件及其元数据
+type File struct {
+	// Name 原始文件名，不含目录
+	Name string
+	// ContentType 内容类型，如 "application/pdf"
+	ContentType string
+	// Data 文件内容
+	Data []byte
+	// Corrected 提取时纠错码纠正的字节数，嵌入时忽略
+	Corrected int
+}
+
+// NewFile 根据文件名创建File，内容类型按扩展名推断
+func NewFile(name string, data []byte) *File {
+	name = baseName(name)
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	return &File{Name: name, ContentType: contentType, Data: data}
+}
+
+// EmbedFile 将文件及其文件名、大小和内容类型一起嵌入图像
+func EmbedFile(s Steganographer, img image.Image, f *File, opts *Options) (image.Image, error) {
+	data, err := f.marshal()
+	if err != nil {
+		return nil, err
+	}
+	return embed(s, img, data, FlagFile, opts)
+}
+
+// ExtractFile 从图像中提取文件。嵌入的是普通数据而不是文件时，
+// 返回的File只有Data，Name和ContentType为空
+func ExtractFile(s Steganographer, img image.Image, opts *Options) (*File, error) {
+	data, header, err := extract(s, img, opts)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{Data: data}
+	if header.Flags&FlagFile != 0 {
+		if f, err = parseFile(data); err != nil {
+			return nil, err
+		}
+	}
+	f.Corrected = header.Corrected
+	return f, nil
+}
+
+// FilePayloadSize 返回文件经选项处理后实际占用的字节数（不含负载头）
+func FilePayloadSize(f *File, opts *Options) int {
+	data, err := f.marshal()
+	if err != nil {
+		return f.size()
+	}
+	return PayloadSize(data, opts)
+}
+
+func (f *File) size() int {
+	return fileMetaSize + len(baseName(f.Name)) + len(f.ContentType) + len(f.Data)
+}
+
+// 序列化文件元数据和内容，文件名只保留最后一级
+func (f *File) marshal() ([]byte, error) {
+	name := baseName(f.Name)
+	if len(name) > maxFileNameLen {
+		return nil, fmt.Errorf("文件名太长: %d 字节", len(name))
+	}
+	if len(f.ContentType) > maxContentTypeLen {
+		return nil, fmt.Errorf("内容类型太长: %d 字节", len(f.ContentType))
+	}
+	if uint64(len(f.Data)) > 1<<32-1 {
+		return nil, fmt.Errorf("文件太大: %d 字节", len(f.Data))
+	}
+
+	buf := make([]byte, 0, f.size())
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(name)))
+	buf = append(buf, name...)
+	buf = append(buf, byte(len(f.ContentType)))
+	buf = append(buf, f.ContentType...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(f.Data)))
+	return append(buf, f.Data...), nil
+}
+
+// 解析文件元数据，文件名来自不可信的图像，只保留最后一级
+func parseFile(buf []byte) (*File, error) {
+	errTruncated := fmt.Errorf("%w: 文件元数据不完整", ErrCorrupted)
+
+	if len(buf) < 2 {
+		return nil, errTruncated
+	}
+	nameLen := int(binary.BigEndian.Uint16(buf))
+	buf = buf[2:]
+	if len(buf) < nameLen+1 {
+		return nil, errTruncated
+	}
+	name := string(buf[:nameLen])
+	buf = buf[nameLen:]
+
+	typeLen := int(buf[0])
+	buf = buf[1:]
+	if len(buf) < typeLen+4 {
+		return nil, errTruncated
+	}
+	contentType := string(buf[:typeLen])
+	buf = buf[typeLen:]
+
+	size := binary.BigEndian.Uint32(buf)
+	buf = buf[4:]
+	if uint64(len(buf)) != uint64(size) {
+		return nil, fmt.Errorf("%w: 文件大小不符: 记录 %d 字节，实际 %d 字节", ErrCorrupted, size, len(buf))
+	}
+	return &File{Name: baseName(name), ContentType: contentType, Data: buf}, nil
+}
+
+// 去掉目录部分，防止提取时写到其他位置
+func baseName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEmbedFile_RoundTrip(t *testing.T) {
+	content := []byte("%PDF-1.4\x00\x01\x02 binary content")
+	f := NewFile("docs/report.pdf", content)
+	if f.Name != "report.pdf" || f.ContentType != "application/pdf" {
+		t.Fatalf("NewFile() = %q, %q; want report.pdf, application/pdf", f.Name, f.ContentType)
+	}
+
+	opts := &Options{Passphrase: "secret"}
+	lsb := NewLSB()
+	encodedImg, err := EmbedFile(lsb, newGradientImage(128), f, opts)
+	if err != nil {
+		t.Fatalf("EmbedFile() error = %v", err)
+	}
+
+	extracted, err := ExtractFile(lsb, encodedImg, opts)
+	if err != nil {
+		t.Fatalf("ExtractFile() error = %v", err)
+	}
+	if extracted.Name != f.Name || extracted.ContentType != f.ContentType || !bytes.Equal(extracted.Data, content) {
+		t.Errorf("ExtractFile() = %q, %q, % x", extracted.Name, extracted.ContentType, extracted.Data)
+	}
+
+	// Extract只返回文件内容
+	data, err := Extract(lsb, encodedImg, opts)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("Extract() = % x; want % x", data, content)
+	}
+
+	_, header, err := Inspect(encodedImg, nil)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if header.Flags&FlagFile == 0 {
+		t.Errorf("header flags = %#x; want FlagFile set", header.Flags)
+	}
+	if int(header.Length) != FilePayloadSize(f, opts) {
+		t.Errorf("header length = %d; want FilePayloadSize() = %d", header.Length, FilePayloadSize(f, opts))
+	}
+}
+
+func TestExtractFile_PlainData(t *testing.T) {
+	lsb := NewLSB()
+	encodedImg, err := lsb.EmbedText(newGradientImage(64), "plain text")
+	if err != nil {
+		t.Fatalf("EmbedText() error = %v", err)
+	}
+	f, err := ExtractFile(lsb, encodedImg, nil)
+	if err != nil {
+		t.Fatalf("ExtractFile() error = %v", err)
+	}
+	if f.Name != "" || string(f.Data) != "plain text" {
+		t.Errorf("ExtractFile() = %+v; want plain data without name", f)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	buf, err := (&File{Name: `..\..\evil.sh`, ContentType: "text/x-sh", Data: []byte("echo")}).marshal()
+	if err != nil {
+		t.Fatalf("marshal() error = %v", err)
+	}
+	f, err := parseFile(buf)
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+	if f.Name != "evil.sh" {
+		t.Errorf("parseFile() name = %q; want evil.sh", f.Name)
+	}
+
+	for i := range buf {
+		if _, err := parseFile(buf[:i]); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("parseFile() with %d bytes error = %v; want ErrCorrupted", i, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package steganography
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// 负载头格式（大端序，共17字节）：
+//
+//	0-1   魔数 "SG"
+//	2     格式版本
+//	3     算法ID
+//	4-7   算法参数
+//	8     标志位
+//	9-12  负载长度
+//	13-16 CRC32校验和（覆盖0-12字节、扩展字段及负载）
+//
+// 设置FlagPadded时，之后附加8字节扩展字段：原始宽度和高度（各4字节）。
+//
+// 版本2为纠错帧，固定部分之后依次为1字节纠错等级和8字节扩展字段（未填充时为0），
+// 共26字节，整体作为一个RS码字附加16字节校验写入图像，负载按纠错等级分块编码
+const (
+	headerVersion    = 1
+	headerVersionECC = 2
+	headerSize       = 17
+	paddedExtSize    = 8
+	eccHeaderSize    = headerSize + 1 + paddedExtSize
+)
+
+var headerMagic = [2]byte{'S', 'G'}
+
+// 算法ID，写入负载头以便提取时识别
+const (
+	AlgorithmLSB uint8 = 1
+	AlgorithmDCT uint8 = 2
+	AlgorithmDWT uint8 = 3
+	AlgorithmF5  uint8 = 4
+)
+
+// 负载头标志位
+const (
+	// FlagEncrypted 表示负载已用口令加密
+	FlagEncrypted uint8 = 1 << iota
+	// FlagRecipients 表示负载已加密给一个或多个公钥接收者
+	FlagRecipients
+	// FlagPadded 表示嵌入时对图像做了填充，负载头中记录了原始宽高
+	FlagPadded
+	// FlagFile 表示负载是带有文件名和内容类型的文件
+	FlagFile
+	// FlagCompressed 表示负载在加密前经过DEFLATE压缩
+	FlagCompressed
+)
+
+// 当前版本能够识别的标志位
+const knownFlags = FlagEncrypted | FlagRecipients | FlagPadded | FlagFile | FlagCompressed
+
+var (
+	// ErrNoHiddenData 表示图像中没有找到隐藏数据
+	ErrNoHiddenData = errors.New("图片中没有隐藏数据")
+	// ErrCorrupted 表示找到了隐藏数据，但数据已损坏
+	ErrCorrupted = errors.New("隐藏数据已损坏")
+)
+
+// Header 是写在负载之前的自描述信息
+type Header struct {
+	Version   uint8
+	Algorithm uint8
+	Params    uint32
+	Flags     uint8
+	Length    uint32
+	CRC       uint32
+
+	// OriginalWidth 和 OriginalHeight 仅在设置FlagPadded时有效
+	OriginalWidth  uint32
+	OriginalHeight uint32
+
+	// ECC 纠错等级，仅版本2有效
+	ECC ECCLevel
+	// Corrected 提取时纠正的字节数，不写入图像
+	Corrected int
+}
+
+// size 返回负载头序列化后的字节数（含扩展字段）
+func (h *Header) size() int {
+	switch {
+	case h.Version == headerVersionECC:
+		return eccHeaderSize
+	case h.Flags&FlagPadded != 0:
+		return headerSize + paddedExtSize
+	}
+	return headerSize
+}
+
+// frameSize 返回整个帧（负载头、负载及纠错校验）写入图像的字节数
+func (h *Header) frameSize() int {
+	if h.Version == headerVersionECC {
+		return eccHeaderCodeword + eccEncodedLen(int(h.Length), h.ECC.parity())
+	}
+	return h.size() + int(h.Length)
+}
+
+// 扩展字段的起始位置
+func (h *Header) extOffset() int {
+	if h.Version == headerVersionECC {
+		return headerSize + 1
+	}
+	return headerSize
+}
+
+// 序列化负载头，CRC根据负载重新计算
+func (h *Header) marshal(payload []byte) []byte {
+	buf := make([]byte, h.size())
+	copy(buf, headerMagic[:])
+	buf[2] = h.Version
+	buf[3] = h.Algorithm
+	binary.BigEndian.PutUint32(buf[4:], h.Params)
+	buf[8] = h.Flags
+	binary.BigEndian.PutUint32(buf[9:], h.Length)
+	if h.Version == headerVersionECC {
+		buf[headerSize] = byte(h.ECC)
+	}
+	if h.Flags&FlagPadded != 0 {
+		binary.BigEndian.PutUint32(buf[h.extOffset():], h.OriginalWidth)
+		binary.BigEndian.PutUint32(buf[h.extOffset()+4:], h.OriginalHeight)
+	}
+
+	h.CRC = headerChecksum(buf, payload)
+	binary.BigEndian.PutUint32(buf[13:], h.CRC)
+	return buf
+}
+
+// 解析负载头的固定部分，只校验魔数和版本，CRC需在读取负载后校验
+func parseHeader(buf []byte) (*Header, error) {
+	if len(buf) < headerSize || buf[0] != headerMagic[0] || buf[1] != headerMagic[1] {
+		return nil, ErrNoHiddenData
+	}
+
+	h := &Header{
+		Version:   buf[2],
+		Algorithm: buf[3],
+		Params:    binary.BigEndian.Uint32(buf[4:]),
+		Flags:     buf[8],
+		Length:    binary.BigEndian.Uint32(buf[9:]),
+		CRC:       binary.BigEndian.Uint32(buf[13:]),
+	}
+	if h.Version != headerVersion && h.Version != headerVersionECC {
+		return nil, fmt.Errorf("%w: 不支持的格式版本 %d", ErrCorrupted, h.Version)
+	}
+	return h, nil
+}
+
+// 解析扩展字段，buf为包含扩展字段的完整负载头
+func (h *Header) parseExtension(buf []byte) error {
+	if len(buf) < h.size() {
+		return fmt.Errorf("%w: 负载头不完整", ErrCorrupted)
+	}
+	if h.Version == headerVersionECC {
+		h.ECC = ECCLevel(buf[headerSize])
+		if h.ECC.parity() == 0 {
+			return fmt.Errorf("%w: 不支持的纠错等级 %d", ErrCorrupted, h.ECC)
+		}
+	}
+	if h.Flags&FlagPadded != 0 {
+		h.OriginalWidth = binary.BigEndian.Uint32(buf[h.extOffset():])
+		h.OriginalHeight = binary.BigEndian.Uint32(buf[h.extOffset()+4:])
+	}
+	return nil
+}
+
+// verify 校验负载的CRC
+func (h *Header) verify(buf, payload []byte) error {
+	if headerChecksum(buf, payload) != h.CRC {
+		return fmt.Errorf("%w: 校验和不匹配", ErrCorrupted)
+	}
+	return nil
+}
+
+func headerChecksum(buf, payload []byte) uint32 {
+	crc := crc32.ChecksumIEEE(buf[:13])
+	crc = crc32.Update(crc, crc32.IEEETable, buf[headerSize:])
+	return crc32.Update(crc, crc32.IEEETable, payload)
+}
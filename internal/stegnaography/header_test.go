@@ -0,0 +1,156 @@
+package steganography
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// 创建一张渐变测试图像
+func newGradientImage(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, color.RGBA{
+				R: uint8(x % 256),
+				G: uint8(y % 256),
+				B: uint8((x + y) % 256),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+func TestHeader_RoundTrip(t *testing.T) {
+	payload := []byte("payload")
+	h := &Header{
+		Version:   headerVersion,
+		Algorithm: AlgorithmDCT,
+		Params:    0x01020304,
+		Flags:     FlagEncrypted | FlagRecipients,
+		Length:    uint32(len(payload)),
+	}
+	buf := h.marshal(payload)
+	if len(buf) != headerSize {
+		t.Fatalf("marshal() returned %d bytes; want %d", len(buf), headerSize)
+	}
+
+	parsed, err := parseHeader(buf)
+	if err != nil {
+		t.Fatalf("parseHeader() error = %v", err)
+	}
+	if *parsed != *h {
+		t.Errorf("parseHeader() = %+v; want %+v", *parsed, *h)
+	}
+	if err := parsed.verify(buf, payload); err != nil {
+		t.Errorf("verify() error = %v", err)
+	}
+	if err := parsed.verify(buf, []byte("Payload")); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("verify() with modified payload error = %v; want ErrCorrupted", err)
+	}
+}
+
+func TestHeader_PaddedExtension(t *testing.T) {
+	payload := []byte("payload")
+	h := &Header{
+		Version:        headerVersion,
+		Algorithm:      AlgorithmDWT,
+		Flags:          FlagPadded,
+		Length:         uint32(len(payload)),
+		OriginalWidth:  1001,
+		OriginalHeight: 1003,
+	}
+	buf := h.marshal(payload)
+	if len(buf) != headerSize+paddedExtSize {
+		t.Fatalf("marshal() returned %d bytes; want %d", len(buf), headerSize+paddedExtSize)
+	}
+
+	parsed, err := parseHeader(buf)
+	if err != nil {
+		t.Fatalf("parseHeader() error = %v", err)
+	}
+	if err := parsed.parseExtension(buf); err != nil {
+		t.Fatalf("parseExtension() error = %v", err)
+	}
+	if *parsed != *h {
+		t.Errorf("parsed header = %+v; want %+v", *parsed, *h)
+	}
+
+	// 扩展字段同样受CRC保护
+	buf[headerSize+3] ^= 1
+	if err := parsed.verify(buf, payload); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("verify() with modified extension error = %v; want ErrCorrupted", err)
+	}
+}
+
+func TestExtract_NoHiddenData(t *testing.T) {
+	img := newGradientImage(256)
+
+	for _, name := range Algorithms() {
+		t.Run(name, func(t *testing.T) {
+			stego, err := New(name)
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", name, err)
+			}
+			if _, err := stego.ExtractBytes(img); !errors.Is(err, ErrNoHiddenData) {
+				t.Errorf("ExtractBytes() on clean image error = %v; want ErrNoHiddenData", err)
+			}
+		})
+	}
+}
+
+func TestExtract_Corrupted(t *testing.T) {
+	lsb := NewLSBWithConfig(ChannelR, 1)
+	encodedImg, err := lsb.EmbedText(newGradientImage(64), "Hello, World!")
+	if err != nil {
+		t.Fatalf("EmbedText() error = %v", err)
+	}
+
+	// 翻转负载中的一位（位于负载头之后）
+	nrgba := encodedImg.(*image.NRGBA)
+	index := headerSize*8 + 3
+	x, y := index%64, index/64
+	c := nrgba.NRGBAAt(x, y)
+	c.R ^= 1
+	nrgba.SetNRGBA(x, y, c)
+
+	if _, err := lsb.ExtractText(nrgba); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("ExtractText() on modified image error = %v; want ErrCorrupted", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	img := newGradientImage(256)
+	for _, name := range Algorithms() {
+		t.Run(name, func(t *testing.T) {
+			stego, err := New(name)
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", name, err)
+			}
+			encodedImg, err := Embed(stego, img, []byte("inspect"), &Options{Passphrase: "secret"})
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+
+			got, header, err := Inspect(encodedImg, nil)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if got != name {
+				t.Errorf("Inspect() algorithm = %q; want %q", got, name)
+			}
+			if header.Flags&FlagEncrypted == 0 {
+				t.Errorf("Inspect() flags = %#x; want FlagEncrypted set", header.Flags)
+			}
+			if want := PayloadSize([]byte("inspect"), &Options{Passphrase: "secret"}); int(header.Length) != want {
+				t.Errorf("Inspect() length = %d; want %d", header.Length, want)
+			}
+		})
+	}
+
+	if _, _, err := Inspect(img, nil); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("Inspect() on clean image error = %v; want ErrNoHiddenData", err)
+	}
+}
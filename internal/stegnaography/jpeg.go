@@ -0,0 +1,129 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+
+	"steganography-tool/internal/jpegcoef"
+)
+
+// F5 用F5算法在JPEG的量化系数中嵌入和提取数据，见f5.go。
+// 只支持基线JPEG，渐进式JPEG返回jpegcoef.ErrUnsupported
+type F5 struct{}
+
+// NewF5 创建F5隐写实例
+func NewF5() *F5 {
+	return &F5{}
+}
+
+func (*F5) Name() string {
+	return "F5"
+}
+
+// Embed 将数据嵌入JPEG，返回新的JPEG数据
+func (*F5) Embed(jpeg, data []byte, opts *Options) ([]byte, error) {
+	return embedF5(jpeg, data, 0, opts)
+}
+
+// EmbedFile 将文件及其元数据嵌入JPEG，返回新的JPEG数据
+func (*F5) EmbedFile(jpeg []byte, f *File, opts *Options) ([]byte, error) {
+	data, err := f.marshal()
+	if err != nil {
+		return nil, err
+	}
+	return embedF5(jpeg, data, FlagFile, opts)
+}
+
+func embedF5(jpeg, data []byte, flags uint8, opts *Options) ([]byte, error) {
+	f, err := readF5(jpeg)
+	if err != nil {
+		return nil, err
+	}
+	img, l := f.placeholder(opts)
+	if _, err := embedPayload(f, img, data, flags, opts, l); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := f.coef.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExtractFile 从JPEG中提取文件，嵌入的是普通数据时Name和ContentType为空
+func (*F5) ExtractFile(jpeg []byte, opts *Options) (*File, error) {
+	f, err := readF5(jpeg)
+	if err != nil {
+		return nil, err
+	}
+	img, l := f.placeholder(opts)
+	data, header, err := extractPayload(f, img, opts, l)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{Data: data}
+	if header.Flags&FlagFile != 0 {
+		if file, err = parseFile(data); err != nil {
+			return nil, err
+		}
+	}
+	file.Corrected = header.Corrected
+	return file, nil
+}
+
+// Inspect 读取并校验负载头，不解密负载，opts中只有Key会被使用
+func (*F5) Inspect(jpeg []byte, opts *Options) (*Header, error) {
+	f, err := readF5(jpeg)
+	if err != nil {
+		return nil, err
+	}
+	img, l := f.placeholder(opts)
+	_, header, err := locateFrame(f, img, l)
+	return header, err
+}
+
+// Capacity 返回JPEG预计可嵌入的字节数（已扣除负载头）。
+// 实际容量取决于收缩的系数个数，可能略有出入
+func (*F5) Capacity(jpeg []byte) (int, error) {
+	f, err := readF5(jpeg)
+	if err != nil {
+		return 0, err
+	}
+	return max(f.expectedBits()/8-headerSize, 0), nil
+}
+
+func readF5(jpeg []byte) (*f5Carrier, error) {
+	coef, err := jpegcoef.Decode(bytes.NewReader(jpeg))
+	if err != nil {
+		return nil, err
+	}
+	return newF5Carrier(coef), nil
+}
+
+// InspectJPEG 依次尝试所有已注册的JPEG算法，返回第一个读取到有效负载头的算法名称
+// 和负载头。不支持该JPEG编码方式的算法视为没有数据
+func InspectJPEG(jpeg []byte, opts *Options) (string, *Header, error) {
+	var corrupted error
+	for _, name := range JPEGAlgorithms() {
+		s, err := NewJPEG(name)
+		if err != nil {
+			return "", nil, err
+		}
+		header, err := s.Inspect(jpeg, opts)
+		switch {
+		case err == nil:
+			return name, header, nil
+		case errors.Is(err, ErrNoHiddenData), errors.Is(err, jpegcoef.ErrUnsupported):
+		case errors.Is(err, ErrCorrupted):
+			if corrupted == nil && !errors.Is(err, errHeaderMismatch) {
+				corrupted = err
+			}
+		default:
+			return "", nil, err
+		}
+	}
+	if corrupted != nil {
+		return "", nil, corrupted
+	}
+	return "", nil, ErrNoHiddenData
+}
@@ -0,0 +1,28 @@
+package steganography
+
+// JPEG标准亮度量化表（ITU-T T.81 附录K），按自然顺序存储，
+// 第一维为垂直频率，与dct2D的系数下标一致
+var jpegLumaQuant = [8][8]int{
+	{16, 11, 10, 16, 24, 40, 51, 61},
+	{12, 12, 14, 19, 26, 58, 60, 55},
+	{14, 13, 16, 24, 40, 57, 69, 56},
+	{14, 17, 22, 29, 51, 87, 80, 62},
+	{18, 22, 37, 56, 68, 109, 103, 77},
+	{24, 35, 55, 64, 81, 104, 113, 92},
+	{49, 64, 78, 87, 103, 121, 120, 101},
+	{72, 92, 95, 98, 112, 100, 103, 99},
+}
+
+// jpegQuantStep 返回image/jpeg以给定质量编码时系数(u, v)的量化步长，
+// 缩放和取整方式与标准库一致
+func jpegQuantStep(quality, u, v int) float64 {
+	quality = max(1, min(100, quality))
+	var scale int
+	if quality < 50 {
+		scale = 5000 / quality
+	} else {
+		scale = 200 - quality*2
+	}
+	step := (jpegLumaQuant[u][v]*scale + 50) / 100
+	return float64(max(1, min(255, step)))
+}
@@ -4,141 +4,273 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"strconv"
+	"image/draw"
+	"math/rand/v2"
+	"slices"
 	"strings"
 )
 
-type LSB struct{}
+// Channel 表示LSB可以使用的颜色通道，可以按位组合
+type Channel uint8
 
-func NewLSB() *LSB {
-	return &LSB{}
-}
+const (
+	ChannelR Channel = 1 << iota
+	ChannelG
+	ChannelB
+	ChannelA
 
-func (l *LSB) EmbedText(img image.Image, text string) (*image.RGBA, error) {
-	bounds := img.Bounds()
-	rgba := image.NewRGBA(bounds)
+	ChannelRGB = ChannelR | ChannelG | ChannelB
+)
 
-	// 首先将原始图片复制到新的RGBA图片中
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			rgba.Set(x, y, img.At(x, y))
+// ParseChannels 解析通道组合，如"rgb"、"r,g,b"、"RGBA"，不区分大小写
+func ParseChannels(spec string) (Channel, error) {
+	var c Channel
+	for _, r := range strings.ToLower(spec) {
+		switch r {
+		case 'r':
+			c |= ChannelR
+		case 'g':
+			c |= ChannelG
+		case 'b':
+			c |= ChannelB
+		case 'a':
+			c |= ChannelA
+		case ',', '+', ' ':
+		default:
+			return 0, fmt.Errorf("未知的通道: %c（可选 R, G, B, A）", r)
 		}
 	}
+	if c == 0 {
+		return 0, fmt.Errorf("没有指定通道")
+	}
+	return c, nil
+}
+
+// 每个通道最多可使用的低位数
+const maxBitsPerChannel = 4
+
+type LSB struct {
+	// Channels 用于嵌入的通道组合
+	Channels Channel
+	// BitsPerChannel 每个通道使用的最低位数，取值1–4
+	BitsPerChannel int
+	// Matching 为true时使用LSB匹配（±1嵌入）代替直接替换最低位，
+	// 可以避免替换产生的值对现象，提取方式不变
+	Matching bool
+}
+
+// NewLSB 创建默认配置的LSB：RGB三个通道，每个通道1位
+func NewLSB() *LSB {
+	return NewLSBWithConfig(ChannelRGB, 1)
+}
+
+// NewLSBWithConfig 创建使用指定通道和位数的LSB
+func NewLSBWithConfig(channels Channel, bitsPerChannel int) *LSB {
+	return &LSB{
+		Channels:       channels,
+		BitsPerChannel: bitsPerChannel,
+	}
+}
+
+// Name 返回算法名称
+func (l *LSB) Name() string {
+	return "LSB"
+}
+
+// Constraints LSB对图像尺寸没有要求
+func (l *LSB) Constraints() Constraints {
+	return Constraints{}
+}
 
+// Capacity 每个像素的每个选定通道存储BitsPerChannel位，需扣除负载头
+func (l *LSB) Capacity(bounds image.Rectangle) int {
+	return payloadCapacity(l, bounds)
+}
+
+func (l *LSB) EmbedText(img image.Image, text string) (image.Image, error) {
 	// 将文本转换为UTF-8字节数组
-	textBytes := []byte(text)
-	// 添加结束标记
-	textBytes = append(textBytes, 0)
+	return l.EmbedBytes(img, []byte(text))
+}
 
-	// 将字节数组转换为二进制字符串
-	var binText strings.Builder
-	for _, b := range textBytes {
-		fmt.Fprintf(&binText, "%08b", b)
+func (l *LSB) ExtractText(img image.Image) (string, error) {
+	data, err := l.ExtractBytes(img)
+	if err != nil {
+		return "", err
 	}
+	return string(data), nil
+}
 
-	binString := binText.String()
-	binLen := len(binString)
+// EmbedBytes 嵌入任意二进制数据，数据中可以包含0字节
+func (l *LSB) EmbedBytes(img image.Image, data []byte) (image.Image, error) {
+	return Embed(l, img, data, nil)
+}
 
-	// 检查图片容量是否足够
-	if binLen > (bounds.Dx() * bounds.Dy()) {
-		return nil, fmt.Errorf("图片太小，无法存储这么多文本")
-	}
-
-	// 嵌入文本数据
-	binIndex := 0
-	for y := bounds.Min.Y; y < bounds.Max.Y && binIndex < binLen; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X && binIndex < binLen; x++ {
-			// 获取当前像素的RGBA值
-			r, g, b, a := rgba.At(x, y).RGBA()
-
-			// 将颜色值转换为8位
-			r8 := uint8(r >> 8)
-			g8 := uint8(g >> 8)
-			b8 := uint8(b >> 8)
-			a8 := uint8(a >> 8)
-
-			// 修改红色通道的最低位
-			if binString[binIndex] == '1' {
-				r8 |= 1 // 设置最低位为1
-			} else {
-				r8 &= 0xFE // 设置最低位为0
+// ExtractBytes 提取由EmbedBytes嵌入的二进制数据
+func (l *LSB) ExtractBytes(img image.Image) ([]byte, error) {
+	return Extract(l, img, nil)
+}
+
+func (l *LSB) algorithmID() uint8 {
+	return AlgorithmLSB
+}
+
+// 参数格式：低8位为通道组合，8-15位为每通道位数
+func (l *LSB) params() uint32 {
+	return uint32(l.Channels) | uint32(l.BitsPerChannel)<<8
+}
+
+// variants 枚举所有通道和位数组合，提取时用于识别嵌入时的配置
+func (l *LSB) variants() []carrier {
+	var variants []carrier
+	for channels := ChannelR; channels <= ChannelR|ChannelG|ChannelB|ChannelA; channels++ {
+		for bits := 1; bits <= maxBitsPerChannel; bits++ {
+			if channels != l.Channels || bits != l.BitsPerChannel {
+				variants = append(variants, NewLSBWithConfig(channels, bits))
 			}
-			binIndex++
-
-			// 设置修改后的像素值
-			rgba.Set(x, y, color.RGBA{
-				R: r8,
-				G: g8,
-				B: b8,
-				A: a8,
-			})
 		}
 	}
+	return variants
+}
 
-	return rgba, nil
+// 检查配置是否有效
+func (l *LSB) validate() error {
+	if l.Channels == 0 || l.Channels&^(ChannelR|ChannelG|ChannelB|ChannelA) != 0 {
+		return fmt.Errorf("无效的通道组合: %#x", uint8(l.Channels))
+	}
+	if l.BitsPerChannel < 1 || l.BitsPerChannel > maxBitsPerChannel {
+		return fmt.Errorf("每个通道的位数必须在1到%d之间", maxBitsPerChannel)
+	}
+	return nil
 }
 
-func (l *LSB) ExtractText(img image.Image) (string, error) {
-	bounds := img.Bounds()
-	var result []byte
-	bitCount := 0
-	currentByte := uint8(0)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			// 获取红色通道值
-			r, _, _, _ := img.At(x, y).RGBA()
-			r8 := uint8(r >> 8)
-
-			// 提取最低位
-			bit := r8 & 1
-
-			// 构建字节
-			currentByte = (currentByte << 1) | bit
-			bitCount++
-
-			if bitCount == 8 {
-				// 检查是否到达结束标记
-				if currentByte == 0 {
-					return string(result), nil
-				}
-
-				result = append(result, currentByte)
-				bitCount = 0
-				currentByte = 0
-			}
+// 返回选定通道在NRGBA像素中的偏移（R=0, G=1, B=2, A=3）
+func (l *LSB) channelOffsets() []int {
+	var offsets []int
+	for i := 0; i < 4; i++ {
+		if l.Channels&(1<<i) != 0 {
+			offsets = append(offsets, i)
 		}
 	}
-
-	return string(result), nil
+	return offsets
 }
 
-// 辅助函数：将文本转换为二进制字符串
-func textToBinary(text string) string {
-	var binary strings.Builder
-	for _, char := range text {
-		// 使用 %08b 确保每个字符都是8位
-		fmt.Fprintf(&binary, "%08b", char)
+func (l *LSB) capacityBits(bounds image.Rectangle) int {
+	if l.validate() != nil {
+		return 0
 	}
-	// 添加结束标记
-	fmt.Fprintf(&binary, "%08b", 0)
-	return binary.String()
+	return bounds.Dx() * bounds.Dy() * len(l.channelOffsets()) * l.BitsPerChannel
+}
+
+// 将位置编号转换为像素坐标、通道偏移和位平面
+func (l *LSB) locate(bounds image.Rectangle, offsets []int, slot int) (x, y, channel, plane int) {
+	perPixel := len(offsets) * l.BitsPerChannel
+	pixel := slot / perPixel
+	rem := slot % perPixel
+	return bounds.Min.X + pixel%bounds.Dx(), bounds.Min.Y + pixel/bounds.Dx(),
+		offsets[rem/l.BitsPerChannel], rem % l.BitsPerChannel
 }
 
-// 辅助函数：将二进制字符串转换为文本
-func binaryToText(binary string) string {
-	var text strings.Builder
-	for i := 0; i < len(binary); i += 8 {
-		if i+8 > len(binary) {
-			break
+func (l *LSB) embedBits(img image.Image, bits []int, lay layout) (image.Image, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	// 首先将原始图片复制到新的NRGBA图片中，使用非预乘的颜色值，
+	// 保证半透明像素保存为PNG后嵌入的比特不会因换算而丢失
+	nrgba := image.NewNRGBA(bounds)
+	draw.Draw(nrgba, bounds, img, bounds.Min, draw.Src)
+
+	// 检查图片容量是否足够
+	total := l.capacityBits(bounds)
+	if len(bits) > total {
+		return nil, fmt.Errorf("图片太小，无法存储这么多数据")
+	}
+
+	// 匹配模式下先在副本中计算每个通道的目标低位，再统一调整
+	target := nrgba.Pix
+	if l.Matching {
+		target = slices.Clone(nrgba.Pix)
+	}
+
+	// 按密钥决定的顺序嵌入比特数据
+	offsets := l.channelOffsets()
+	for i, slot := range slotOrder(lay.key, total, len(bits)) {
+		x, y, channel, plane := l.locate(bounds, offsets, slot)
+		offset := nrgba.PixOffset(x, y) + channel
+
+		if bits[i] == 1 {
+			target[offset] |= 1 << plane
+		} else {
+			target[offset] &^= 1 << plane
 		}
-		if n, err := strconv.ParseUint(binary[i:i+8], 2, 8); err == nil {
-			// 检查是否到达结束标记
-			if n == 0 {
-				break
+	}
+
+	if l.Matching {
+		for i, want := range target {
+			if want != nrgba.Pix[i] {
+				nrgba.Pix[i] = matchLowBits(nrgba.Pix[i], want, l.BitsPerChannel)
 			}
-			text.WriteRune(rune(n))
 		}
 	}
-	return text.String()
+
+	return nrgba, nil
+}
+
+// matchLowBits 返回低k位与want相同、且与value最接近的取值，
+// 距离相同时随机选择加或减。k=1时即为经典的±1 LSB匹配
+func matchLowBits(value, want uint8, k int) uint8 {
+	step := 1 << k
+	mask := step - 1
+	base := int(value)&^mask | int(want)&mask
+
+	best := -1
+	for _, candidate := range []int{base - step, base, base + step} {
+		if candidate < 0 || candidate > 255 {
+			continue
+		}
+		if best < 0 {
+			best = candidate
+			continue
+		}
+		d, bestD := abs(candidate-int(value)), abs(best-int(value))
+		if d < bestD || (d == bestD && rand.IntN(2) == 0) {
+			best = candidate
+		}
+	}
+	return uint8(best)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (l *LSB) extractBits(img image.Image, n int, lay layout) ([]int, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	total := l.capacityBits(bounds)
+	if n > total {
+		return nil, fmt.Errorf("图片太小，无法读取%d位数据", n)
+	}
+
+	offsets := l.channelOffsets()
+	bits := make([]int, 0, n)
+	for _, slot := range slotOrder(lay.key, total, n) {
+		x, y, channel, plane := l.locate(bounds, offsets, slot)
+
+		// 获取非预乘的通道值
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		values := [4]uint8{c.R, c.G, c.B, c.A}
+
+		// 提取对应的位
+		bits = append(bits, int(values[channel]>>plane&1))
+	}
+
+	return bits, nil
 }
@@ -1,10 +1,14 @@
 package steganography
 
 import (
+	"bytes"
+	"errors"
 	"image"
 	"image/color"
+	_ "image/jpeg"
 	"image/png"
 	"os"
+	"slices"
 	"testing"
 )
 
@@ -140,8 +144,8 @@ func TestLSB_WithRealImage(t *testing.T) {
 	}
 	defer imgFile.Close()
 
-	// 解码原始图片
-	originalImg, err := png.Decode(imgFile)
+	// 解码原始图片（Test.png 实际为JPEG编码，按内容识别格式）
+	originalImg, _, err := image.Decode(imgFile)
 	if err != nil {
 		t.Fatalf("无法解码测试图片: %v", err)
 	}
@@ -196,3 +200,213 @@ func TestLSB_WithRealImage(t *testing.T) {
 		t.Logf("测试成功！文本正确嵌入并提取: %s", extractedText)
 	}
 }
+
+func TestLSB_KeyedOrder(t *testing.T) {
+	lsb := NewLSB()
+	text := "scattered across the whole image"
+	cover := newGradientImage(128)
+
+	encodedImg, err := Embed(lsb, cover, []byte(text), &Options{Key: "secret key"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	extracted, err := Extract(lsb, encodedImg, &Options{Key: "secret key"})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if string(extracted) != text {
+		t.Errorf("Text mismatch:\nwant: %q\ngot:  %q", text, extracted)
+	}
+
+	// 没有密钥或密钥错误时无法找到数据
+	for _, key := range []string{"", "wrong key"} {
+		_, err := Extract(lsb, encodedImg, &Options{Key: key})
+		if !errors.Is(err, ErrNoHiddenData) {
+			t.Errorf("Extract() with key %q error = %v; want ErrNoHiddenData", key, err)
+		}
+	}
+
+	// 被修改的像素应分散在整张图片中，而不是集中在前几行
+	rows := make(map[int]bool)
+	encoded := encodedImg.(*image.NRGBA)
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			original := color.NRGBAModel.Convert(cover.At(x, y))
+			if encoded.NRGBAAt(x, y) != original {
+				rows[y] = true
+			}
+		}
+	}
+	if len(rows) < 64 {
+		t.Errorf("modified pixels span only %d rows; want them scattered", len(rows))
+	}
+}
+
+func TestLSB_Config(t *testing.T) {
+	bounds := image.Rect(0, 0, 64, 64)
+	text := "多通道多位嵌入 multi-channel multi-bit"
+
+	testCases := []struct {
+		name         string
+		channels     Channel
+		bits         int
+		wantCapacity int
+		wantErr      bool
+	}{
+		{"红色通道1位", ChannelR, 1, 64*64/8 - headerSize, false},
+		{"RGB 1位", ChannelRGB, 1, 64*64*3/8 - headerSize, false},
+		{"RGB 2位", ChannelRGB, 2, 64*64*6/8 - headerSize, false},
+		{"RGBA 4位", ChannelRGB | ChannelA, 4, 64*64*16/8 - headerSize, false},
+		{"位数为0", ChannelRGB, 0, 0, true},
+		{"位数超出", ChannelRGB, 5, 0, true},
+		{"没有通道", 0, 1, 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lsb := NewLSBWithConfig(tc.channels, tc.bits)
+			if got := lsb.Capacity(bounds); got != tc.wantCapacity {
+				t.Errorf("Capacity() = %d; want %d", got, tc.wantCapacity)
+			}
+
+			encodedImg, err := lsb.EmbedText(newGradientImage(64), text)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("EmbedText() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			// 默认配置的LSB也应能识别其他配置嵌入的数据
+			for _, reader := range []*LSB{lsb, NewLSB()} {
+				extracted, err := reader.ExtractText(encodedImg)
+				if err != nil {
+					t.Fatalf("ExtractText() error = %v", err)
+				}
+				if extracted != text {
+					t.Errorf("Text mismatch:\nwant: %q\ngot:  %q", text, extracted)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChannels(t *testing.T) {
+	if got, err := ParseChannels("R,g+B"); err != nil || got != ChannelRGB {
+		t.Errorf("ParseChannels(\"R,g+B\") = %#x, %v; want %#x", uint8(got), err, uint8(ChannelRGB))
+	}
+	if got, err := ParseChannels("rgba"); err != nil || got != ChannelRGB|ChannelA {
+		t.Errorf("ParseChannels(\"rgba\") = %#x, %v", uint8(got), err)
+	}
+	for _, spec := range []string{"", ",", "rgbx"} {
+		if _, err := ParseChannels(spec); err == nil {
+			t.Errorf("ParseChannels(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestLSB_TranslucentSurvivesPNG(t *testing.T) {
+	// 半透明像素在PNG编解码后嵌入的比特不应丢失
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), 128, uint8(64 + x)})
+		}
+	}
+
+	lsb := NewLSBWithConfig(ChannelRGB|ChannelA, 2)
+	encodedImg, err := lsb.EmbedText(img, "translucent")
+	if err != nil {
+		t.Fatalf("EmbedText() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, encodedImg); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	decodedImg, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+
+	extracted, err := lsb.ExtractText(decodedImg)
+	if err != nil {
+		t.Fatalf("ExtractText() error = %v", err)
+	}
+	if extracted != "translucent" {
+		t.Errorf("ExtractText() = %q; want %q", extracted, "translucent")
+	}
+}
+
+func TestLSB_Matching(t *testing.T) {
+	for _, bitsPerChannel := range []int{1, 2} {
+		lsb := NewLSBWithConfig(ChannelRGB, bitsPerChannel)
+		lsb.Matching = true
+		cover := newGradientImage(128)
+		text := "LSB matching hides without pairs-of-values artifacts"
+
+		encodedImg, err := lsb.EmbedText(cover, text)
+		if err != nil {
+			t.Fatalf("EmbedText() error = %v", err)
+		}
+
+		// 使用普通的LSB读取器提取
+		extracted, err := NewLSB().ExtractText(encodedImg)
+		if err != nil {
+			t.Fatalf("ExtractText() error = %v", err)
+		}
+		if extracted != text {
+			t.Errorf("Text mismatch:\nwant: %q\ngot:  %q", text, extracted)
+		}
+
+		// 除取值边界外，每个通道的改变量不超过 2^(k-1)，且存在跨越替换组的修改
+		maxDelta := 1 << (bitsPerChannel - 1)
+		crossed := 0
+		encoded := encodedImg.(*image.NRGBA)
+		for y := 0; y < 128; y++ {
+			for x := 0; x < 128; x++ {
+				before := color.NRGBAModel.Convert(cover.At(x, y)).(color.NRGBA)
+				after := encoded.NRGBAAt(x, y)
+				for _, pair := range [][2]uint8{{before.R, after.R}, {before.G, after.G}, {before.B, after.B}} {
+					delta := abs(int(pair[1]) - int(pair[0]))
+					nearEdge := int(pair[0]) < maxDelta || int(pair[0]) > 255-maxDelta
+					if delta > maxDelta && !nearEdge {
+						t.Fatalf("channel changed by %d at (%d,%d); want <= %d", delta, x, y, maxDelta)
+					}
+					if pair[0]>>bitsPerChannel != pair[1]>>bitsPerChannel {
+						crossed++
+					}
+				}
+			}
+		}
+		if crossed == 0 {
+			t.Errorf("bits=%d: no value left its replacement group; matching not applied", bitsPerChannel)
+		}
+	}
+}
+
+func TestMatchLowBits(t *testing.T) {
+	testCases := []struct {
+		value, want uint8
+		k           int
+		options     []uint8
+	}{
+		{10, 10, 1, []uint8{10}},
+		{10, 11, 1, []uint8{9, 11}},
+		{0, 1, 1, []uint8{1}},
+		{255, 0, 1, []uint8{254}},
+		{8, 3, 2, []uint8{7}},
+		{8, 2, 2, []uint8{6, 10}},
+		{0, 3, 2, []uint8{3}},
+	}
+
+	for _, tc := range testCases {
+		for i := 0; i < 20; i++ {
+			got := matchLowBits(tc.value, tc.want, tc.k)
+			if !slices.Contains(tc.options, got) {
+				t.Fatalf("matchLowBits(%d, %d, %d) = %d; want one of %v", tc.value, tc.want, tc.k, got, tc.options)
+			}
+		}
+	}
+}
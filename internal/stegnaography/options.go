@@ -0,0 +1,270 @@
+package steganography
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Options 控制负载在嵌入前的处理方式，nil等价于零值
+type Options struct {
+	// Passphrase 非空时使用口令加密负载，提取时需提供相同口令
+	Passphrase string
+	// Recipients 非空时将负载加密给这些公钥，不能与Passphrase同时使用
+	Recipients []*PublicKey
+	// Identities 提取时用于解密接收者负载的私钥
+	Identities []*PrivateKey
+	// Key 非空时按密钥生成的伪随机顺序分散嵌入位置，提取时需提供相同密钥
+	Key string
+	// Compress 为true时在加密前使用DEFLATE压缩负载，压缩无效时自动跳过，
+	// 提取时根据负载头自动解压
+	Compress bool
+	// Pad 图像尺寸不满足算法要求时的填充方式。填充只在嵌入过程中使用，
+	// 输出图像保持原始尺寸和内容，原始宽高记录在负载头中
+	Pad PadMode
+	// ECC 非ECCNone时为负载头和负载添加Reed–Solomon纠错码，提取时自动纠正误码，
+	// 纠正的字节数记录在Header.Corrected中
+	ECC ECCLevel
+}
+
+// 返回嵌入顺序密钥，opts为nil时为空
+func (o *Options) key() string {
+	if o == nil {
+		return ""
+	}
+	return o.Key
+}
+
+// 返回纠错等级，opts为nil时不纠错
+func (o *Options) ecc() ECCLevel {
+	if o == nil {
+		return ECCNone
+	}
+	return o.ECC
+}
+
+// 返回填充方式，opts为nil时不填充
+func (o *Options) pad() PadMode {
+	if o == nil {
+		return PadNone
+	}
+	return o.Pad
+}
+
+// Embed 按选项处理数据后嵌入图像
+func Embed(s Steganographer, img image.Image, data []byte, opts *Options) (image.Image, error) {
+	return embed(s, img, data, 0, opts)
+}
+
+// 嵌入数据，flags为描述数据本身的标志位，与选项产生的标志位一起写入负载头
+func embed(s Steganographer, img image.Image, data []byte, flags uint8, opts *Options) (image.Image, error) {
+	c, err := carrierOf(s)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	l := layout{key: opts.key(), region: bounds}
+	constraints := s.Constraints()
+	if err := constraints.Check(bounds); err != nil {
+		if opts.pad() == PadNone {
+			return nil, err
+		}
+
+		// 填充至满足要求的尺寸后嵌入，再裁剪回原始尺寸
+		width, height := constraints.PadSize(bounds.Dx(), bounds.Dy())
+		encoded, err := embedPayload(c, padImage(img, width, height, opts.pad()), data, flags, opts, l)
+		if err != nil {
+			return nil, err
+		}
+		return cropImage(encoded, bounds), nil
+	}
+	return embedPayload(c, img, data, flags, opts, l)
+}
+
+// Extract 从图像中提取数据，并按负载头中的标志位还原。
+// 嵌入的是文件时只返回文件内容，需要文件名等元数据时使用ExtractFile
+func Extract(s Steganographer, img image.Image, opts *Options) ([]byte, error) {
+	data, header, err := extract(s, img, opts)
+	if err != nil {
+		return nil, err
+	}
+	if header.Flags&FlagFile != 0 {
+		f, err := parseFile(data)
+		if err != nil {
+			return nil, err
+		}
+		data = f.Data
+	}
+	return data, nil
+}
+
+// 提取数据并解密，同时返回负载头
+func extract(s Steganographer, img image.Image, opts *Options) ([]byte, *Header, error) {
+	c, err := carrierOf(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, l := extractLayout(s, img, opts)
+	return extractPayload(c, img, opts, l)
+}
+
+// Inspect 依次尝试所有已注册的算法，返回第一个读取到有效负载头的算法名称和负载头。
+// 只校验CRC，不解密负载，opts中只有Key会被使用
+func Inspect(img image.Image, opts *Options) (string, *Header, error) {
+	var corrupted error
+	for _, name := range Algorithms() {
+		s, err := New(name)
+		if err != nil {
+			return "", nil, err
+		}
+		c, ok := s.(carrier)
+		if !ok {
+			continue
+		}
+
+		padded, l := extractLayout(s, img, opts)
+		_, header, err := locateFrame(c, padded, l)
+		if err == nil {
+			return name, header, nil
+		}
+		if corrupted == nil && errors.Is(err, ErrCorrupted) && !errors.Is(err, errHeaderMismatch) {
+			corrupted = err
+		}
+	}
+	if corrupted != nil {
+		return "", nil, corrupted
+	}
+	return "", nil, ErrNoHiddenData
+}
+
+// extractConstrainer 是提取时对尺寸要求更严格的算法，例如枚举的参数组合
+// 各有不同的尺寸要求，一次填充到满足所有组合，避免每个组合各填充一次
+type extractConstrainer interface {
+	extractConstraints() Constraints
+}
+
+// 尺寸不满足算法要求时填充图像，返回待读取的图像及布局。
+// 填充出的部分不含数据，填充方式不影响提取
+func extractLayout(s Steganographer, img image.Image, opts *Options) (image.Image, layout) {
+	bounds := img.Bounds()
+	l := layout{key: opts.key(), region: bounds}
+	constraints := s.Constraints()
+	if e, ok := s.(extractConstrainer); ok {
+		constraints = e.extractConstraints()
+	}
+	if constraints.Check(bounds) != nil {
+		width, height := constraints.PadSize(bounds.Dx(), bounds.Dy())
+		img = padImage(img, width, height, PadEdge)
+	}
+	return img, l
+}
+
+// PayloadSize 返回数据经选项处理后实际占用的字节数（不含负载头），
+// 可与Capacity比较判断是否能够嵌入。启用压缩时会实际压缩一次数据
+func PayloadSize(data []byte, opts *Options) int {
+	size := len(data)
+	if opts != nil && opts.Compress {
+		compressed, _ := compress(data)
+		size = len(compressed)
+	}
+	return EncodedSize(size, opts)
+}
+
+// EncodedSize 返回压缩后（不压缩时即原始）为size字节的数据经加密和纠错后
+// 占用的字节数（不含负载头）。调用方已缓存压缩后的大小时用它代替PayloadSize，
+// 避免每次重新压缩
+func EncodedSize(size int, opts *Options) int {
+	if opts == nil {
+		return size
+	}
+	if opts.Passphrase != "" {
+		size += passphraseOverhead
+	}
+	if len(opts.Recipients) > 0 {
+		size += recipientsOverhead(len(opts.Recipients))
+	}
+	if nsym := opts.ECC.parity(); nsym > 0 {
+		// 纠错帧的负载头比普通负载头长，差值一并计入
+		size = eccEncodedLen(size, nsym) + eccHeaderCodeword - headerSize
+	}
+	return size
+}
+
+func carrierOf(s Steganographer) (carrier, error) {
+	c, ok := s.(carrier)
+	if !ok {
+		return nil, fmt.Errorf("算法%s不支持自定义选项", s.Name())
+	}
+	return c, nil
+}
+
+// 按选项对数据进行处理，返回处理后的负载和对应的标志位
+func encodePayload(data []byte, opts *Options) ([]byte, uint8, error) {
+	if opts == nil {
+		return data, 0, nil
+	}
+
+	if opts.Passphrase != "" && len(opts.Recipients) > 0 {
+		return nil, 0, fmt.Errorf("口令加密与接收者加密不能同时使用")
+	}
+
+	var flags uint8
+	// 密文无法压缩，压缩必须在加密之前
+	if opts.Compress {
+		if compressed, ok := compress(data); ok {
+			data = compressed
+			flags |= FlagCompressed
+		}
+	}
+	if len(opts.Recipients) > 0 {
+		sealed, err := sealForRecipients(data, opts.Recipients)
+		if err != nil {
+			return nil, 0, err
+		}
+		data = sealed
+		flags |= FlagRecipients
+	}
+	if opts.Passphrase != "" {
+		sealed, err := sealWithPassphrase(data, opts.Passphrase)
+		if err != nil {
+			return nil, 0, err
+		}
+		data = sealed
+		flags |= FlagEncrypted
+	}
+	return data, flags, nil
+}
+
+// 按标志位还原负载
+func decodePayload(payload []byte, flags uint8, opts *Options) ([]byte, error) {
+	if flags&^knownFlags != 0 {
+		return nil, fmt.Errorf("%w: 不支持的标志位 %#x", ErrCorrupted, flags)
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if flags&FlagEncrypted != 0 {
+		plaintext, err := openWithPassphrase(payload, opts.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		payload = plaintext
+	}
+	if flags&FlagRecipients != 0 {
+		plaintext, err := openForRecipients(payload, opts.Identities)
+		if err != nil {
+			return nil, err
+		}
+		payload = plaintext
+	}
+	if flags&FlagCompressed != 0 {
+		decompressed, err := decompress(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = decompressed
+	}
+	return payload, nil
+}
@@ -0,0 +1,72 @@
+package steganography
+
+import (
+	"image"
+	"image/draw"
+)
+
+// PadMode 表示图像尺寸不满足算法要求时的处理方式
+type PadMode int
+
+const (
+	// PadNone 不填充，尺寸不满足要求时返回错误
+	PadNone PadMode = iota
+	// PadEdge 复制边缘像素扩展图像
+	PadEdge
+	// PadMirror 以边缘为轴镜像扩展图像
+	PadMirror
+)
+
+// layout 描述嵌入位置的选择方式，提取时必须与嵌入时一致
+type layout struct {
+	// key 嵌入顺序密钥，为空时按顺序嵌入
+	key string
+	// region 原始图像区域，填充出的部分不嵌入数据
+	region image.Rectangle
+}
+
+// 将图像填充至width×height，左上角保持不变
+func padImage(img image.Image, width, height int, mode PadMode) *image.NRGBA {
+	bounds := img.Bounds()
+	padded := image.NewNRGBA(image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+width, bounds.Min.Y+height))
+	draw.Draw(padded, bounds, img, bounds.Min, draw.Src)
+
+	for y := 0; y < height; y++ {
+		sy := padIndex(y, bounds.Dy(), mode)
+		for x := 0; x < width; x++ {
+			if x < bounds.Dx() && y < bounds.Dy() {
+				continue
+			}
+			sx := padIndex(x, bounds.Dx(), mode)
+			padded.Set(bounds.Min.X+x, bounds.Min.Y+y, padded.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return padded
+}
+
+// 返回填充位置i在长度为n的原始数据中对应的下标
+func padIndex(i, n int, mode PadMode) int {
+	if i < n {
+		return i
+	}
+	if mode == PadMirror {
+		// 不重复边缘像素的镜像：n-2, n-3, ...，超出两倍长度后继续往返
+		period := 2 * (n - 1)
+		if period <= 0 {
+			return 0
+		}
+		i %= period
+		if i >= n {
+			i = period - i
+		}
+		return i
+	}
+	return n - 1
+}
+
+// 裁剪回原始区域
+func cropImage(img image.Image, region image.Rectangle) *image.NRGBA {
+	cropped := image.NewNRGBA(region)
+	draw.Draw(cropped, region, img, region.Min, draw.Src)
+	return cropped
+}
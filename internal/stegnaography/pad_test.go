@@ -0,0 +1,130 @@
+package steganography
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPadIndex(t *testing.T) {
+	tests := []struct {
+		mode PadMode
+		n    int
+		want []int
+	}{
+		{PadEdge, 3, []int{0, 1, 2, 2, 2, 2}},
+		{PadMirror, 3, []int{0, 1, 2, 1, 0, 1, 2, 1}},
+		{PadMirror, 1, []int{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			if got := padIndex(i, tt.n, tt.mode); got != want {
+				t.Errorf("padIndex(%d, %d, %d) = %d; want %d", i, tt.n, tt.mode, got, want)
+			}
+		}
+	}
+}
+
+func TestConstraints_PadSize(t *testing.T) {
+	tests := []struct {
+		c             Constraints
+		width, height int
+		wantW, wantH  int
+	}{
+		{Constraints{}, 1001, 1003, 1001, 1003},
+		{Constraints{BlockSize: 8}, 1001, 1003, 1008, 1008},
+		{Constraints{BlockSize: 8}, 256, 256, 256, 256},
+	}
+	for _, tt := range tests {
+		w, h := tt.c.PadSize(tt.width, tt.height)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%+v.PadSize(%d, %d) = %d, %d; want %d, %d",
+				tt.c, tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+		}
+		if err := tt.c.Check(image.Rect(0, 0, w, h)); err != nil {
+			t.Errorf("%+v.Check() on padded size error = %v", tt.c, err)
+		}
+	}
+}
+
+func TestEmbed_Padding(t *testing.T) {
+	tests := []struct {
+		name          string
+		stego         Steganographer
+		width, height int
+	}{
+		{"DCT", NewDCTSteganography(), 1001, 1003},
+		{"DWT", NewDWTSteganography(), 301, 201},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bounds := image.Rect(0, 0, tt.width, tt.height)
+			cover := image.NewNRGBA(bounds)
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					cover.Set(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+				}
+			}
+			data := []byte("填充而不是裁剪")
+
+			// 不填充时仍然报告尺寸错误
+			if _, err := Embed(tt.stego, cover, data, nil); err == nil {
+				t.Fatal("Embed() without padding expected size error, got nil")
+			}
+
+			encodedImg, err := Embed(tt.stego, cover, data, &Options{Pad: PadMirror})
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+			if encodedImg.Bounds() != bounds {
+				t.Fatalf("Embed() bounds = %v; want %v", encodedImg.Bounds(), bounds)
+			}
+
+			extracted, err := tt.stego.ExtractBytes(encodedImg)
+			if err != nil {
+				t.Fatalf("ExtractBytes() error = %v", err)
+			}
+			if string(extracted) != string(data) {
+				t.Errorf("ExtractBytes() = %q; want %q", extracted, data)
+			}
+
+			// 尺寸与负载头中记录的不一致时视为损坏
+			cropped := cropImage(encodedImg, image.Rect(0, 0, tt.width-2, tt.height))
+			if _, err := tt.stego.ExtractBytes(cropped); !errors.Is(err, ErrCorrupted) && !errors.Is(err, ErrNoHiddenData) {
+				t.Errorf("ExtractBytes() on cropped image error = %v; want ErrCorrupted or ErrNoHiddenData", err)
+			}
+		})
+	}
+}
+
+func TestEmbed_PaddingLeavesEdgeUntouched(t *testing.T) {
+	dct := NewDCTSteganography()
+	cover := image.NewNRGBA(image.Rect(0, 0, 201, 203))
+	for y := 0; y < 203; y++ {
+		for x := 0; x < 201; x++ {
+			cover.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	// 写满容量，确保所有完整的块都被修改
+	data := make([]byte, dct.Capacity(cover.Bounds()))
+	encodedImg, err := Embed(dct, cover, data, &Options{Pad: PadEdge})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	// 不足一个块的右边缘和下边缘不携带数据，应保持原样
+	encoded := encodedImg.(*image.NRGBA)
+	for y := 0; y < 203; y++ {
+		for x := 0; x < 201; x++ {
+			if x < 200 && y < 200 {
+				continue
+			}
+			if got, want := encoded.NRGBAAt(x, y), cover.NRGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d, %d) = %v; want %v", x, y, got, want)
+			}
+		}
+	}
+}
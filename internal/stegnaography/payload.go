@@ -0,0 +1,313 @@
+package steganography
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// carrier 是各算法底层的比特读写能力，负载的封装由本文件统一处理
+type carrier interface {
+	// Constraints 返回算法对图像尺寸的要求
+	Constraints() Constraints
+	// algorithmID 返回写入负载头的算法ID
+	algorithmID() uint8
+	// params 返回写入负载头的算法参数
+	params() uint32
+	// capacityBits 返回给定尺寸的图像最多可嵌入的比特数
+	capacityBits(bounds image.Rectangle) int
+	// embedBits 将比特流依次嵌入图像，只使用l.region内的位置，
+	// l.key决定嵌入位置的顺序
+	embedBits(img image.Image, bits []int, l layout) (image.Image, error)
+	// extractBits 按与embedBits相同的顺序读取前n个比特
+	extractBits(img image.Image, n int, l layout) ([]int, error)
+}
+
+// variantCarrier 是可以枚举其他参数组合的载体，
+// 提取时若按当前参数找不到数据，会依次尝试这些组合
+type variantCarrier interface {
+	variants() []carrier
+}
+
+// paramCarrier 是负载头位置与部分参数无关的载体。读到负载头后按其中的参数
+// 切换读取方式，这些参数不必由variants枚举
+type paramCarrier interface {
+	// withParams 返回按params读取负载的载体，负载头位置与当前载体不同时返回nil
+	withParams(params uint32) carrier
+}
+
+// 负载头中的参数与c不符时，换用能按这些参数读取负载的载体
+func adaptCarrier(c carrier, header *Header) carrier {
+	if header.Algorithm != c.algorithmID() || header.Params == c.params() {
+		return c
+	}
+	if p, ok := c.(paramCarrier); ok {
+		if adapted := p.withParams(header.Params); adapted != nil {
+			return adapted
+		}
+	}
+	return c
+}
+
+// ErrTooLarge 表示处理后的数据超出了图像容量
+var ErrTooLarge = errors.New("数据太长，超出图像容量")
+
+// errHeaderMismatch 表示读到了负载头，但与当前载体的算法或参数不符
+var errHeaderMismatch = fmt.Errorf("%w: 负载头与算法参数不符", ErrCorrupted)
+
+// 计算载体在扣除负载头后可嵌入的字节数，需要填充时还要扣除扩展字段
+func payloadCapacity(c carrier, bounds image.Rectangle) int {
+	size := headerSize
+	if c.Constraints().Check(bounds) != nil {
+		size += paddedExtSize
+	}
+	return max(c.capacityBits(bounds)/8-size, 0)
+}
+
+// 按选项处理数据，添加负载头后嵌入图像。flags为描述数据本身的标志位。
+// img可能是填充后的图像，此时l.region为原始区域，原始宽高记录在负载头中
+func embedPayload(c carrier, img image.Image, data []byte, flags uint8, opts *Options, l layout) (image.Image, error) {
+	payload, optFlags, err := encodePayload(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &Header{
+		Version:   headerVersion,
+		Algorithm: c.algorithmID(),
+		Params:    c.params(),
+		Flags:     flags | optFlags,
+		Length:    uint32(len(payload)),
+	}
+	if l.region != img.Bounds() {
+		header.Flags |= FlagPadded
+		header.OriginalWidth = uint32(l.region.Dx())
+		header.OriginalHeight = uint32(l.region.Dy())
+	}
+
+	var framed []byte
+	if level := opts.ecc(); level != ECCNone {
+		if level.parity() == 0 {
+			return nil, fmt.Errorf("未知的纠错等级: %d", level)
+		}
+		// 负载头和负载分别编码，负载头固定使用较强的校验
+		header.Version = headerVersionECC
+		header.ECC = level
+		framed = rsEncode(header.marshal(payload), eccHeaderParity)
+		framed = append(framed, eccEncode(payload, level.parity())...)
+	} else {
+		framed = append(header.marshal(payload), payload...)
+	}
+
+	bits := bytesToBits(framed)
+	if len(bits) > c.capacityBits(l.region) {
+		return nil, ErrTooLarge
+	}
+	return c.embedBits(img, bits, l)
+}
+
+// 读取并校验负载，最后按标志位还原，同时返回负载头。先按载体当前参数读取，
+// 找不到数据时再尝试载体的其他参数组合
+func extractPayload(c carrier, img image.Image, opts *Options, l layout) ([]byte, *Header, error) {
+	payload, header, err := locateFrame(c, img, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := decodePayload(payload, header.Flags, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, header, nil
+}
+
+// 读取负载，找不到时尝试载体支持的其他参数组合。读到的负载头参数不符时同样
+// 尝试，不影响读取方式的参数（如符号嵌入的强度）不同时会出现这种情况
+func locateFrame(c carrier, img image.Image, l layout) ([]byte, *Header, error) {
+	payload, header, err := readFrame(c, img, l)
+	if errors.Is(err, ErrNoHiddenData) || errors.Is(err, errHeaderMismatch) {
+		if v, ok := c.(variantCarrier); ok {
+			vPayload, vHeader, vErr := readFrameVariants(v.variants(), img, l)
+			if vErr == nil || !errors.Is(err, errHeaderMismatch) || !errors.Is(vErr, ErrNoHiddenData) {
+				payload, header, err = vPayload, vHeader, vErr
+			}
+		}
+	}
+	return payload, header, err
+}
+
+// 依次尝试各参数组合，返回第一个通过校验的负载
+func readFrameVariants(variants []carrier, img image.Image, l layout) ([]byte, *Header, error) {
+	var corrupted error
+	for _, variant := range variants {
+		payload, header, err := readFrame(variant, img, l)
+		if err == nil {
+			return payload, header, nil
+		}
+		// 其他组合读到的负载头可能只是巧合，参数不符时忽略
+		if corrupted == nil && errors.Is(err, ErrCorrupted) && !errors.Is(err, errHeaderMismatch) {
+			corrupted = err
+		}
+	}
+	if corrupted != nil {
+		return nil, nil, corrupted
+	}
+	return nil, nil, ErrNoHiddenData
+}
+
+// 先读取并解析负载头，再按长度读取负载并校验CRC
+func readFrame(c carrier, img image.Image, l layout) ([]byte, *Header, error) {
+	capacity := c.capacityBits(l.region)
+	if capacity < headerSize*8 {
+		return nil, nil, ErrNoHiddenData
+	}
+
+	bits, err := c.extractBits(img, headerSize*8, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := parseHeader(bitsToBytes(bits))
+	if err == nil && header.Version == headerVersion {
+		c = adaptCarrier(c, header)
+		return readPlainFrame(c, img, l, header, c.capacityBits(l.region))
+	}
+
+	// 纠错帧的负载头经过RS编码，直接解析可能因误码失败，纠错后再解析
+	if capacity >= eccHeaderCodeword*8 {
+		payload, eccHeader, eccErr := readECCFrame(c, img, l, capacity)
+		if !errors.Is(eccErr, ErrNoHiddenData) {
+			return payload, eccHeader, eccErr
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%w: 纠错帧负载头无法恢复", ErrCorrupted)
+	}
+	return nil, nil, err
+}
+
+// 读取不带纠错的帧
+func readPlainFrame(c carrier, img image.Image, l layout, header *Header, capacity int) ([]byte, *Header, error) {
+	if err := checkHeader(c, header); err != nil {
+		return nil, nil, err
+	}
+
+	// 读取扩展字段
+	headerBits := header.size() * 8
+	if headerBits > capacity {
+		return nil, nil, fmt.Errorf("%w: 负载头不完整", ErrCorrupted)
+	}
+	if uint64(header.Length)*8 > uint64(capacity-headerBits) {
+		return nil, nil, fmt.Errorf("%w: 数据长度无效: %d 字节", ErrCorrupted, header.Length)
+	}
+
+	bits, err := c.extractBits(img, headerBits+int(header.Length)*8, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	headerBuf := bitsToBytes(bits[:headerBits])
+	if err := header.parseExtension(headerBuf); err != nil {
+		return nil, nil, err
+	}
+	payload := bitsToBytes(bits[headerBits:])
+	if err := header.verify(headerBuf, payload); err != nil {
+		return nil, nil, err
+	}
+	if err := checkRegion(header, l); err != nil {
+		return nil, nil, err
+	}
+	return payload, header, nil
+}
+
+// 读取纠错帧：先纠正负载头码字，再按纠错等级逐个纠正负载码字
+func readECCFrame(c carrier, img image.Image, l layout, capacity int) ([]byte, *Header, error) {
+	bits, err := c.extractBits(img, eccHeaderCodeword*8, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	headerBuf, corrected, err := rsDecode(bitsToBytes(bits), eccHeaderParity)
+	if err != nil {
+		return nil, nil, ErrNoHiddenData
+	}
+	header, err := parseHeader(headerBuf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.Version != headerVersionECC {
+		return nil, nil, ErrNoHiddenData
+	}
+	c = adaptCarrier(c, header)
+	capacity = c.capacityBits(l.region)
+	if err := header.parseExtension(headerBuf); err != nil {
+		return nil, nil, err
+	}
+	if err := checkHeader(c, header); err != nil {
+		return nil, nil, err
+	}
+
+	nsym := header.ECC.parity()
+	encodedLen := eccEncodedLen(int(header.Length), nsym)
+	if uint64(header.Length) > uint64(capacity/8) || eccHeaderCodeword+encodedLen > capacity/8 {
+		return nil, nil, fmt.Errorf("%w: 数据长度无效: %d 字节", ErrCorrupted, header.Length)
+	}
+
+	bits, err = c.extractBits(img, (eccHeaderCodeword+encodedLen)*8, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, fixed, err := eccDecode(bitsToBytes(bits[eccHeaderCodeword*8:]), int(header.Length), nsym)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := header.verify(headerBuf, payload); err != nil {
+		return nil, nil, err
+	}
+	if err := checkRegion(header, l); err != nil {
+		return nil, nil, err
+	}
+	header.Corrected = corrected + fixed
+	return payload, header, nil
+}
+
+// 检查负载头中的算法和参数是否与载体一致
+func checkHeader(c carrier, header *Header) error {
+	if header.Algorithm != c.algorithmID() {
+		return fmt.Errorf("%w（算法ID %d）", errHeaderMismatch, header.Algorithm)
+	}
+	if header.Params != c.params() {
+		return fmt.Errorf("%w（参数 %#x）", errHeaderMismatch, header.Params)
+	}
+	return nil
+}
+
+// 填充嵌入的图像在保存时已裁剪回原始尺寸，尺寸不符说明图像被缩放或裁剪过
+func checkRegion(header *Header, l layout) error {
+	if header.Flags&FlagPadded != 0 &&
+		(int(header.OriginalWidth) != l.region.Dx() || int(header.OriginalHeight) != l.region.Dy()) {
+		return fmt.Errorf("%w: 图像尺寸 %dx%d 与嵌入时的 %dx%d 不一致", ErrCorrupted,
+			l.region.Dx(), l.region.Dy(), header.OriginalWidth, header.OriginalHeight)
+	}
+	return nil
+}
+
+// 辅助函数：将字节数组转换为比特流（高位在前）
+func bytesToBits(data []byte) []int {
+	bits := make([]int, 0, len(data)*8)
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, int((b>>uint(i))&1))
+		}
+	}
+	return bits
+}
+
+// 辅助函数：将比特流转换为字节数组，不足8位的部分被丢弃
+func bitsToBytes(bits []int) []byte {
+	data := make([]byte, 0, len(bits)/8)
+	for i := 0; i+8 <= len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			b = b<<1 | byte(bits[i+j]&1)
+		}
+		data = append(data, b)
+	}
+	return data
+}
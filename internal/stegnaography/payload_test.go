@@ -0,0 +1,68 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEmbedBytes_BinaryRoundTrip(t *testing.T) {
+	// 包含0字节及所有取值的二进制数据
+	data := []byte{0x00, 0x00, 0xff, 0x00, 0x01}
+	for i := 0; i < 256; i++ {
+		data = append(data, byte(i))
+	}
+
+	for _, name := range Algorithms() {
+		t.Run(name, func(t *testing.T) {
+			stego, err := New(name)
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", name, err)
+			}
+
+			img := image.NewRGBA(image.Rect(0, 0, 512, 512))
+			for y := 0; y < 512; y++ {
+				for x := 0; x < 512; x++ {
+					img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+				}
+			}
+
+			encodedImg, err := stego.EmbedBytes(img, data)
+			if err != nil {
+				t.Fatalf("EmbedBytes() error = %v", err)
+			}
+
+			extracted, err := stego.ExtractBytes(encodedImg)
+			if err != nil {
+				t.Fatalf("ExtractBytes() error = %v", err)
+			}
+			if !bytes.Equal(extracted, data) {
+				t.Errorf("Data mismatch:\nwant: % x\ngot:  % x", data, extracted)
+			}
+		})
+	}
+}
+
+func TestEmbedBytes_TooLarge(t *testing.T) {
+	lsb := NewLSB()
+	bounds := image.Rect(0, 0, 16, 16)
+	img := image.NewRGBA(bounds)
+
+	data := make([]byte, lsb.Capacity(bounds)+1)
+	if _, err := lsb.EmbedBytes(img, data); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("EmbedBytes() error = %v; want ErrTooLarge", err)
+	}
+}
+
+func TestBitsConversion(t *testing.T) {
+	data := []byte{0x00, 0x80, 0x01, 0xa5}
+	bits := bytesToBits(data)
+	if len(bits) != len(data)*8 {
+		t.Fatalf("bytesToBits() returned %d bits; want %d", len(bits), len(data)*8)
+	}
+	if got := bitsToBytes(bits); !bytes.Equal(got, data) {
+		t.Errorf("bitsToBytes(bytesToBits(x)) = % x; want % x", got, data)
+	}
+}
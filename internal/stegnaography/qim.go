@@ -0,0 +1,51 @@
+package steganography
+
+import (
+	"crypto/sha256"
+	"math"
+	"math/rand/v2"
+)
+
+// 用于派生抖动序列种子的域分隔前缀
+const ditherDomain = "steganography-tool qim dither v1\x00"
+
+// 量化索引调制（QIM）：比特0取量化步长的偶数倍，比特1取奇数倍。
+// 提取时按最近的倍数判断，只要扰动小于step/2即可正确还原
+
+// qimEmbed 将x移动到与bit对应的最近格点
+func qimEmbed(x, step float64, bit int) float64 {
+	q := math.Round(x / step)
+	if int(q)&1 != bit&1 {
+		// 移到另一侧相邻的倍数，保证修改量不超过一个步长
+		if x/step > q {
+			q++
+		} else {
+			q--
+		}
+	}
+	return q * step
+}
+
+// qimExtract 返回x所在格点对应的比特
+func qimExtract(x, step float64) int {
+	return int(math.Round(x/step)) & 1
+}
+
+// qimSoft 返回x所在格点对应的比特及判决置信度：x正好位于格点上时为1，
+// 位于两类格点正中（判决边界）时为0
+func qimSoft(x, step float64) (int, float64) {
+	r := x / step
+	q := math.Round(r)
+	return int(q) & 1, 1 - 2*math.Abs(r-q)
+}
+
+// qimDither 返回前n个比特使用的抖动量，在[-step/2, step/2)内均匀分布。
+// 各系数的格点按抖动量平移（抖动调制），不知道密钥时无法从系数判断格点位置
+func qimDither(key string, n int, step float64) []float64 {
+	rng := rand.New(rand.NewChaCha8(sha256.Sum256([]byte(ditherDomain + key))))
+	dither := make([]float64, n)
+	for i := range dither {
+		dither[i] = (rng.Float64() - 0.5) * step
+	}
+	return dither
+}
@@ -0,0 +1,237 @@
+package steganography
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// 密钥的文本编码前缀
+const (
+	publicKeyPrefix  = "stegopub1"
+	privateKeyPrefix = "stegosec1"
+)
+
+// 每个接收者占用 临时公钥(32) + 包裹后的数据密钥(32+16)
+const (
+	fileKeySize   = 32
+	stanzaSize    = 32 + fileKeySize + chacha20poly1305.Overhead
+	maxRecipients = 255
+	hkdfInfo      = "steganography-tool X25519 v1"
+)
+
+// ErrNotRecipient 表示提供的私钥都不是该数据的接收者
+var ErrNotRecipient = errors.New("没有匹配的私钥，无法解密")
+
+// PublicKey 是接收者的X25519公钥
+type PublicKey struct {
+	key *ecdh.PublicKey
+}
+
+// PrivateKey 是接收者的X25519私钥
+type PrivateKey struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateKey 生成一对新的X25519密钥
+func GenerateKey() (*PrivateKey, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %v", err)
+	}
+	return &PrivateKey{key: key}, nil
+}
+
+// Public 返回私钥对应的公钥
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{key: k.key.PublicKey()}
+}
+
+// String 将公钥导出为可分享的文本
+func (k *PublicKey) String() string {
+	return publicKeyPrefix + base64.RawURLEncoding.EncodeToString(k.key.Bytes())
+}
+
+// String 将私钥导出为文本，请妥善保管
+func (k *PrivateKey) String() string {
+	return privateKeyPrefix + base64.RawURLEncoding.EncodeToString(k.key.Bytes())
+}
+
+// ParsePublicKey 导入由PublicKey.String导出的公钥
+func ParsePublicKey(s string) (*PublicKey, error) {
+	raw, err := decodeKey(s, publicKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("无效的公钥: %v", err)
+	}
+	return &PublicKey{key: key}, nil
+}
+
+// ParsePrivateKey 导入由PrivateKey.String导出的私钥
+func ParsePrivateKey(s string) (*PrivateKey, error) {
+	raw, err := decodeKey(s, privateKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("无效的私钥: %v", err)
+	}
+	return &PrivateKey{key: key}, nil
+}
+
+func decodeKey(s, prefix string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, prefix) {
+		return nil, fmt.Errorf("密钥格式错误，应以%s开头", prefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s[len(prefix):])
+	if err != nil {
+		return nil, fmt.Errorf("密钥格式错误: %v", err)
+	}
+	return raw, nil
+}
+
+// 接收者加密额外占用的字节数
+func recipientsOverhead(n int) int {
+	return 1 + n*stanzaSize + chacha20poly1305.NonceSize + chacha20poly1305.Overhead
+}
+
+// 使用随机数据密钥加密负载，并为每个接收者包裹一份数据密钥，
+// 输出格式为 接收者数量|接收者块...|随机数|密文
+func sealForRecipients(plaintext []byte, recipients []*PublicKey) ([]byte, error) {
+	if len(recipients) > maxRecipients {
+		return nil, fmt.Errorf("接收者太多，最多支持%d个", maxRecipients)
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, fmt.Errorf("生成数据密钥失败: %v", err)
+	}
+
+	sealed := make([]byte, 0, recipientsOverhead(len(recipients))+len(plaintext))
+	sealed = append(sealed, byte(len(recipients)))
+	for _, recipient := range recipients {
+		stanza, err := wrapFileKey(fileKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+		sealed = append(sealed, stanza...)
+	}
+
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %v", err)
+	}
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, plaintext, nil), nil
+}
+
+// 用任一匹配的私钥解密由sealForRecipients生成的数据
+func openForRecipients(sealed []byte, identities []*PrivateKey) ([]byte, error) {
+	if len(identities) == 0 {
+		return nil, ErrNotRecipient
+	}
+	if len(sealed) < 1 {
+		return nil, fmt.Errorf("%w: 密文太短", ErrCorrupted)
+	}
+	count := int(sealed[0])
+	if len(sealed) < recipientsOverhead(count) {
+		return nil, fmt.Errorf("%w: 密文太短", ErrCorrupted)
+	}
+
+	var fileKey []byte
+	stanzas := sealed[1 : 1+count*stanzaSize]
+	for i := 0; i < count && fileKey == nil; i++ {
+		stanza := stanzas[i*stanzaSize : (i+1)*stanzaSize]
+		for _, identity := range identities {
+			if key, err := unwrapFileKey(stanza, identity); err == nil {
+				fileKey = key
+				break
+			}
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNotRecipient
+	}
+
+	aead, err := chacha20poly1305.New(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	body := sealed[1+count*stanzaSize:]
+	nonce := body[:chacha20poly1305.NonceSize]
+	plaintext, err := aead.Open(nil, nonce, body[chacha20poly1305.NonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 认证失败", ErrCorrupted)
+	}
+	return plaintext, nil
+}
+
+// 使用临时密钥与接收者公钥协商出包裹密钥，加密数据密钥
+func wrapFileKey(fileKey []byte, recipient *PublicKey) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成临时密钥失败: %v", err)
+	}
+	shared, err := ephemeral.ECDH(recipient.key)
+	if err != nil {
+		return nil, fmt.Errorf("密钥协商失败: %v", err)
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	aead, err := stanzaAEAD(shared, ephemeralPub, recipient.key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个包裹密钥只使用一次，随机数可以固定为0
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	return aead.Seal(ephemeralPub, nonce, fileKey, nil), nil
+}
+
+func unwrapFileKey(stanza []byte, identity *PrivateKey) ([]byte, error) {
+	ephemeralPub, err := ecdh.X25519().NewPublicKey(stanza[:32])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := identity.key.ECDH(ephemeralPub)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := stanzaAEAD(shared, stanza[:32], identity.key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	return aead.Open(nil, nonce, stanza[32:], nil)
+}
+
+func stanzaAEAD(shared, ephemeralPub, recipientPub []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeralPub)+len(recipientPub))
+	salt = append(salt, ephemeralPub...)
+	salt = append(salt, recipientPub...)
+
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(hkdfInfo)), wrapKey); err != nil {
+		return nil, fmt.Errorf("派生密钥失败: %v", err)
+	}
+	return chacha20poly1305.New(wrapKey)
+}
@@ -0,0 +1,96 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEmbed_Recipients(t *testing.T) {
+	alice, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	bob, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	eve, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	lsb := NewLSB()
+	secret := []byte("给Alice和Bob的悄悄话")
+	opts := &Options{Recipients: []*PublicKey{alice.Public(), bob.Public()}}
+
+	encodedImg, err := Embed(lsb, newGradientImage(128), secret, opts)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	for name, identity := range map[string]*PrivateKey{"alice": alice, "bob": bob} {
+		t.Run(name, func(t *testing.T) {
+			extracted, err := Extract(lsb, encodedImg, &Options{Identities: []*PrivateKey{identity}})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if !bytes.Equal(extracted, secret) {
+				t.Errorf("Data mismatch:\nwant: %q\ngot:  %q", secret, extracted)
+			}
+		})
+	}
+
+	t.Run("非接收者", func(t *testing.T) {
+		_, err := Extract(lsb, encodedImg, &Options{Identities: []*PrivateKey{eve}})
+		if !errors.Is(err, ErrNotRecipient) {
+			t.Errorf("Extract() error = %v; want ErrNotRecipient", err)
+		}
+	})
+
+	t.Run("未提供私钥", func(t *testing.T) {
+		_, err := lsb.ExtractBytes(encodedImg)
+		if !errors.Is(err, ErrNotRecipient) {
+			t.Errorf("ExtractBytes() error = %v; want ErrNotRecipient", err)
+		}
+	})
+}
+
+func TestKey_ExportImport(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	priv, err := ParsePrivateKey(key.String())
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() error = %v", err)
+	}
+	if priv.String() != key.String() {
+		t.Errorf("ParsePrivateKey() round trip mismatch")
+	}
+
+	pub, err := ParsePublicKey(" " + key.Public().String() + "\n")
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if pub.String() != key.Public().String() {
+		t.Errorf("ParsePublicKey() round trip mismatch")
+	}
+
+	// 公钥和私钥不能混用
+	if _, err := ParsePublicKey(key.String()); err == nil {
+		t.Error("ParsePublicKey() accepted a private key")
+	}
+	if _, err := ParsePrivateKey("stegosec1!!!"); err == nil {
+		t.Error("ParsePrivateKey() accepted malformed input")
+	}
+}
+
+func TestEmbed_PassphraseAndRecipientsConflict(t *testing.T) {
+	key, _ := GenerateKey()
+	opts := &Options{Passphrase: "pass", Recipients: []*PublicKey{key.Public()}}
+	if _, err := Embed(NewLSB(), newGradientImage(128), []byte("x"), opts); err == nil {
+		t.Error("Embed() expected error when both passphrase and recipients are set")
+	}
+}
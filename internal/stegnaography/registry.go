@@ -0,0 +1,98 @@
+package steganography
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func() Steganographer{}
+	// 保持注册顺序，便于界面按固定顺序展示
+	registryOrder []string
+
+	// 直接处理JPEG数据的算法单独注册，名称与像素域算法不能重复
+	jpegRegistry      = map[string]func() JPEGSteganographer{}
+	jpegRegistryOrder []string
+)
+
+func init() {
+	Register("LSB", func() Steganographer { return NewLSB() })
+	Register("DCT", func() Steganographer { return NewDCTSteganography() })
+	Register("DWT", func() Steganographer { return NewDWTSteganography() })
+	RegisterJPEG("F5", func() JPEGSteganographer { return NewF5() })
+}
+
+// Register 注册一个隐写算法，名称重复时会panic
+func Register(name string, factory func() Steganographer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("steganography: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup || jpegRegistry[name] != nil {
+		panic("steganography: Register called twice for " + name)
+	}
+	registry[name] = factory
+	registryOrder = append(registryOrder, name)
+}
+
+// New 根据名称创建隐写算法实例
+func New(name string) (Steganographer, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("未知的隐写算法: %s", name)
+	}
+	return factory(), nil
+}
+
+// Algorithms 按注册顺序返回所有算法名称
+func Algorithms() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, len(registryOrder))
+	copy(names, registryOrder)
+	return names
+}
+
+// RegisterJPEG 注册一个直接处理JPEG数据的隐写算法，名称重复时会panic
+func RegisterJPEG(name string, factory func() JPEGSteganographer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("steganography: RegisterJPEG factory is nil")
+	}
+	if _, dup := jpegRegistry[name]; dup || registry[name] != nil {
+		panic("steganography: RegisterJPEG called twice for " + name)
+	}
+	jpegRegistry[name] = factory
+	jpegRegistryOrder = append(jpegRegistryOrder, name)
+}
+
+// NewJPEG 根据名称创建直接处理JPEG数据的隐写算法实例
+func NewJPEG(name string) (JPEGSteganographer, error) {
+	registryMu.RLock()
+	factory, ok := jpegRegistry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("未知的JPEG隐写算法: %s", name)
+	}
+	return factory(), nil
+}
+
+// JPEGAlgorithms 按注册顺序返回所有直接处理JPEG数据的算法名称
+func JPEGAlgorithms() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, len(jpegRegistryOrder))
+	copy(names, jpegRegistryOrder)
+	return names
+}
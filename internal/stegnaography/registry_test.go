@@ -0,0 +1,79 @@
+package steganography
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRegistry_Algorithms(t *testing.T) {
+	want := []string{"LSB", "DCT", "DWT"}
+	got := Algorithms()
+	if len(got) != len(want) {
+		t.Fatalf("Algorithms() = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Algorithms()[%d] = %q; want %q", i, got[i], want[i])
+		}
+	}
+
+	if _, err := New("unknown"); err == nil {
+		t.Error("New(\"unknown\") expected error, got nil")
+	}
+
+	if got := JPEGAlgorithms(); len(got) != 1 || got[0] != "F5" {
+		t.Errorf("JPEGAlgorithms() = %v; want [F5]", got)
+	}
+	// 两个注册表的名称互不重叠
+	if _, err := New("F5"); err == nil {
+		t.Error("New(\"F5\") expected error, got nil")
+	}
+	if _, err := NewJPEG("LSB"); err == nil {
+		t.Error("NewJPEG(\"LSB\") expected error, got nil")
+	}
+}
+
+func TestRegistry_CapacityIsUsable(t *testing.T) {
+	bounds := image.Rect(0, 0, 256, 256)
+	img := image.NewRGBA(bounds)
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x + y), 255})
+		}
+	}
+
+	for _, name := range Algorithms() {
+		t.Run(name, func(t *testing.T) {
+			stego, err := New(name)
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", name, err)
+			}
+			if stego.Name() != name {
+				t.Errorf("Name() = %q; want %q", stego.Name(), name)
+			}
+			if err := stego.Constraints().Check(bounds); err != nil {
+				t.Fatalf("Constraints().Check() error = %v", err)
+			}
+
+			// 恰好填满容量的文本应当可以嵌入并完整提取
+			capacity := stego.Capacity(bounds)
+			text := make([]byte, capacity)
+			for i := range text {
+				text[i] = 'a' + byte(i%26)
+			}
+
+			encodedImg, err := stego.EmbedText(img, string(text))
+			if err != nil {
+				t.Fatalf("EmbedText() with %d bytes error = %v", capacity, err)
+			}
+			extracted, err := stego.ExtractText(encodedImg)
+			if err != nil {
+				t.Fatalf("ExtractText() error = %v", err)
+			}
+			if extracted != string(text) {
+				t.Errorf("Text mismatch at capacity %d", capacity)
+			}
+		})
+	}
+}
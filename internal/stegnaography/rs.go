@@ -0,0 +1,208 @@
+package steganography
+
+import "errors"
+
+// GF(256)上的Reed–Solomon编解码，本原多项式为x^8+x^4+x^3+x^2+1(0x11d)，
+// 生成多项式的根为α^0..α^(nsym-1)。多项式按最高次项在前存储，
+// 码字长度不超过255字节，较短的消息视为截短码
+
+// errTooManyErrors 表示码字中的错误超出了纠错能力
+var errTooManyErrors = errors.New("错误过多，无法纠正")
+
+var (
+	gfExp [512]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	// 扩展一倍，乘法时无需取模
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if b == 0 {
+		panic("steganography: GF(256) division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]+255-gfLog[b])%255]
+}
+
+// 返回α^p，p可以为负数
+func gfPowAlpha(p int) byte {
+	return gfExp[((p%255)+255)%255]
+}
+
+// 按Horner法则计算多项式在x处的值
+func polyEval(p []byte, x byte) byte {
+	y := p[0]
+	for _, c := range p[1:] {
+		y = gfMul(y, x) ^ c
+	}
+	return y
+}
+
+func polyMul(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+	for i, a := range p {
+		for j, b := range q {
+			r[i+j] ^= gfMul(a, b)
+		}
+	}
+	return r
+}
+
+// 生成多项式 (x-α^0)(x-α^1)...(x-α^(nsym-1))
+func rsGenerator(nsym int) []byte {
+	g := []byte{1}
+	for i := 0; i < nsym; i++ {
+		g = polyMul(g, []byte{1, gfPowAlpha(i)})
+	}
+	return g
+}
+
+// rsEncode 返回系统码字：原消息后附加nsym字节校验
+func rsEncode(msg []byte, nsym int) []byte {
+	gen := rsGenerator(nsym)
+	out := make([]byte, len(msg)+nsym)
+	copy(out, msg)
+	// 多项式除法，余数即为校验字节
+	for i := range msg {
+		coef := out[i]
+		if coef == 0 {
+			continue
+		}
+		for j := 1; j < len(gen); j++ {
+			out[i+j] ^= gfMul(gen[j], coef)
+		}
+	}
+	copy(out, msg)
+	return out
+}
+
+// rsDecode 纠正码字中的错误，返回原消息及纠正的字节数。
+// 最多可纠正nsym/2个字节错误，codeword会被原地修改
+func rsDecode(codeword []byte, nsym int) ([]byte, int, error) {
+	n := len(codeword)
+	if n <= nsym || n > 255 {
+		return nil, 0, errTooManyErrors
+	}
+
+	synd := make([]byte, nsym)
+	clean := true
+	for i := range synd {
+		synd[i] = polyEval(codeword, gfPowAlpha(i))
+		if synd[i] != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return codeword[:n-nsym], 0, nil
+	}
+
+	// Berlekamp–Massey算法求错误位置多项式，系数按最低次项在前存储
+	locator := []byte{1}
+	prev := []byte{1}
+	errs, shift := 0, 1
+	lastDelta := byte(1)
+	for k := 0; k < nsym; k++ {
+		delta := synd[k]
+		for i := 1; i <= errs && i < len(locator); i++ {
+			delta ^= gfMul(locator[i], synd[k-i])
+		}
+		if delta == 0 {
+			shift++
+			continue
+		}
+
+		scale := gfDiv(delta, lastDelta)
+		next := make([]byte, max(len(locator), len(prev)+shift))
+		copy(next, locator)
+		for i, c := range prev {
+			next[i+shift] ^= gfMul(scale, c)
+		}
+		if 2*errs <= k {
+			prev = locator
+			errs = k + 1 - errs
+			lastDelta = delta
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*errs > nsym {
+		return nil, 0, errTooManyErrors
+	}
+
+	// Chien搜索：下标i对应x^(n-1-i)，错误位置X满足locator(X^-1)=0
+	var positions []int
+	for i := 0; i < n; i++ {
+		if polyEvalLow(locator, gfPowAlpha(-(n-1-i))) == 0 {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) != errs {
+		return nil, 0, errTooManyErrors
+	}
+
+	// Forney算法求错误值：e = X·Ω(X^-1)/Λ'(X^-1)，Ω = S·Λ mod x^nsym
+	omega := make([]byte, nsym)
+	for i, s := range synd {
+		for j, l := range locator {
+			if i+j < nsym {
+				omega[i+j] ^= gfMul(s, l)
+			}
+		}
+	}
+	for _, pos := range positions {
+		x := gfPowAlpha(n - 1 - pos)
+		xInv := gfPowAlpha(-(n - 1 - pos))
+
+		// 特征为2时形式导数只保留奇数次项
+		var deriv byte
+		for i := 1; i < len(locator); i += 2 {
+			deriv ^= gfMul(locator[i], gfPowAlpha(gfLog[xInv]*(i-1)))
+		}
+		if deriv == 0 {
+			return nil, 0, errTooManyErrors
+		}
+		codeword[pos] ^= gfMul(x, gfDiv(polyEvalLow(omega, xInv), deriv))
+	}
+
+	// 纠正后重新校验，防止超出纠错能力时的误纠
+	for i := 0; i < nsym; i++ {
+		if polyEval(codeword, gfPowAlpha(i)) != 0 {
+			return nil, 0, errTooManyErrors
+		}
+	}
+	return codeword[:n-nsym], errs, nil
+}
+
+// 计算最低次项在前存储的多项式在x处的值
+func polyEvalLow(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
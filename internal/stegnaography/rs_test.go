@@ -0,0 +1,52 @@
+package steganography
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestRS_CorrectsErrors(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, nsym := range []int{2, 16, 32, 64} {
+		for _, msgLen := range []int{1, 10, 255 - nsym} {
+			msg := make([]byte, msgLen)
+			for i := range msg {
+				msg[i] = byte(rng.IntN(256))
+			}
+			codeword := rsEncode(msg, nsym)
+
+			// 在随机位置写入不超过nsym/2个错误
+			for errs := 0; errs <= nsym/2; errs += max(1, nsym/8) {
+				damaged := bytes.Clone(codeword)
+				for _, pos := range rng.Perm(len(damaged))[:min(errs, len(damaged))] {
+					damaged[pos] ^= byte(1 + rng.IntN(255))
+				}
+
+				decoded, corrected, err := rsDecode(damaged, nsym)
+				if err != nil {
+					t.Fatalf("nsym=%d len=%d errs=%d: rsDecode() error = %v", nsym, msgLen, errs, err)
+				}
+				if !bytes.Equal(decoded, msg) {
+					t.Fatalf("nsym=%d len=%d errs=%d: rsDecode() returned wrong message", nsym, msgLen, errs)
+				}
+				if want := min(errs, len(damaged)); corrected != want {
+					t.Errorf("nsym=%d len=%d: corrected = %d; want %d", nsym, msgLen, corrected, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRS_TooManyErrors(t *testing.T) {
+	msg := []byte("Reed-Solomon over GF(256)")
+	codeword := rsEncode(msg, 8)
+	for i := 0; i < 8; i++ {
+		codeword[i*3] ^= 0x5a
+	}
+	// 超出纠错能力时应报告错误，而不是返回错误的消息
+	if _, _, err := rsDecode(codeword, 8); !errors.Is(err, errTooManyErrors) {
+		t.Errorf("rsDecode() with 8 errors error = %v; want errTooManyErrors", err)
+	}
+}
@@ -0,0 +1,83 @@
+package steganography
+
+import (
+	"crypto/sha256"
+	"math/rand/v2"
+)
+
+// 用于派生嵌入顺序种子的域分隔前缀
+const (
+	slotOrderDomain  = "steganography-tool slot order v1\x00"
+	interleaveDomain = "steganography-tool interleave v1\x00"
+	f5OrderDomain    = "steganography-tool f5 order v1\x00"
+)
+
+// paramInterleaved 是DCT和DWT参数中表示按interleaveOrder交织嵌入的位，
+// 未设置时按光栅顺序嵌入（早期版本的格式）
+const paramInterleaved uint32 = 1 << 31
+
+// slotOrder 返回前n个比特依次使用的嵌入位置（取值范围[0,total)）。
+// key为空时按顺序排列；否则对全部位置做密钥播种的Fisher–Yates洗牌，
+// 只展开前n项，因此同一密钥下较短的结果总是较长结果的前缀
+func slotOrder(key string, total, n int) []int {
+	n = min(n, total)
+	order := make([]int, n)
+	if key == "" {
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+	return shuffledOrder(sha256.Sum256([]byte(slotOrderDomain+key)), total, n)
+}
+
+// interleaveOrder 返回变换域算法使用的嵌入顺序。位置按8个一组，每组存放
+// 一个字节，各组的顺序按密钥打散，没有密钥时使用固定种子。相邻的字节因此
+// 落在相距较远的块或系数中，裁剪、涂抹或局部压缩造成的连续损坏只影响少数
+// 字节，并分散到不同的码字里。纠错按字节计数，组内比特保持相邻比逐比特
+// 打散能纠正更大范围的损坏
+func interleaveOrder(key string, total, n int) []int {
+	groups := shuffledOrder(sha256.Sum256([]byte(interleaveDomain+key)), total/8, min((n+7)/8, total/8))
+	order := make([]int, 0, n)
+	for _, g := range groups {
+		for i := 0; i < 8 && len(order) < n; i++ {
+			order = append(order, g*8+i)
+		}
+	}
+	return order
+}
+
+// 用给定种子对[0,total)做Fisher–Yates洗牌，只展开前n项
+func shuffledOrder(seed [32]byte, total, n int) []int {
+	order := make([]int, n)
+	rng := rand.New(rand.NewChaCha8(seed))
+
+	// 展开大部分位置时直接在数组上交换，结果与下面的稀疏写法相同
+	if n > total/4 {
+		perm := make([]int, total)
+		for i := range perm {
+			perm[i] = i
+		}
+		for i := 0; i < n; i++ {
+			j := i + rng.IntN(total-i)
+			perm[i], perm[j] = perm[j], perm[i]
+		}
+		copy(order, perm)
+		return order
+	}
+
+	// 只记录被交换过的位置，未出现在表中的位置i对应的值就是i
+	swapped := make(map[int]int, n*2)
+	valueAt := func(i int) int {
+		if v, ok := swapped[i]; ok {
+			return v
+		}
+		return i
+	}
+	for i := 0; i < n; i++ {
+		j := i + rng.IntN(total-i)
+		order[i] = valueAt(j)
+		swapped[j] = valueAt(i)
+	}
+	return order
+}
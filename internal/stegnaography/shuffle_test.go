@@ -0,0 +1,138 @@
+package steganography
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"testing"
+)
+
+func TestSlotOrder(t *testing.T) {
+	t.Run("无密钥按顺序", func(t *testing.T) {
+		order := slotOrder("", 100, 10)
+		for i, slot := range order {
+			if slot != i {
+				t.Fatalf("slotOrder(\"\")[%d] = %d; want %d", i, slot, i)
+			}
+		}
+	})
+
+	t.Run("完整排列", func(t *testing.T) {
+		const total = 1000
+		order := slotOrder("key", total, total)
+		seen := make([]bool, total)
+		for _, slot := range order {
+			if slot < 0 || slot >= total || seen[slot] {
+				t.Fatalf("slotOrder() is not a permutation: slot %d", slot)
+			}
+			seen[slot] = true
+		}
+	})
+
+	t.Run("前缀一致", func(t *testing.T) {
+		short := slotOrder("key", 10000, 50)
+		long := slotOrder("key", 10000, 500)
+		// 展开大部分位置时使用数组交换，结果应与稀疏写法一致
+		full := slotOrder("key", 10000, 10000)
+		for i := range short {
+			if short[i] != long[i] || long[i] != full[i] {
+				t.Fatalf("slotOrder() prefix mismatch at %d: %d, %d, %d", i, short[i], long[i], full[i])
+			}
+		}
+	})
+
+	t.Run("不同密钥", func(t *testing.T) {
+		a := slotOrder("key-a", 10000, 64)
+		b := slotOrder("key-b", 10000, 64)
+		same := 0
+		for i := range a {
+			if a[i] == b[i] {
+				same++
+			}
+		}
+		if same > 4 {
+			t.Errorf("slotOrder() with different keys shares %d of %d positions", same, len(a))
+		}
+	})
+}
+
+func TestInterleaveOrder(t *testing.T) {
+	const total = 8000
+	order := interleaveOrder("", total, total)
+	seen := make([]bool, total)
+	for i, slot := range order {
+		if slot < 0 || slot >= total || seen[slot] {
+			t.Fatalf("interleaveOrder() is not a permutation: slot %d", slot)
+		}
+		seen[slot] = true
+		// 同一字节的8个比特占用相邻位置
+		if i%8 != 0 && slot != order[i-1]+1 {
+			t.Fatalf("interleaveOrder()[%d] = %d; want %d", i, slot, order[i-1]+1)
+		}
+	}
+
+	// 没有密钥时也应打散，相邻字节不应落在相邻位置
+	adjacent := 0
+	for i := 8; i < total; i += 8 {
+		if abs(order[i]-order[i-8]) <= 8 {
+			adjacent++
+		}
+	}
+	if adjacent > 10 {
+		t.Errorf("interleaveOrder() keeps %d of %d bytes next to each other", adjacent, total/8)
+	}
+
+	if keyed := interleaveOrder("key", total, 64); slices.Equal(keyed, order[:64]) {
+		t.Error("interleaveOrder() ignores the key")
+	}
+}
+
+func TestInterleave_BurstDamage(t *testing.T) {
+	data := bytes.Repeat([]byte("burst"), 20)
+	opts := &Options{ECC: ECCHigh}
+
+	for _, c := range []carrier{NewDCTSteganography(), NewDWTSteganography()} {
+		s := c.(Steganographer)
+		t.Run(s.Name(), func(t *testing.T) {
+			encoded, err := Embed(s, newGradientImage(512), data, opts)
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+
+			// 涂黑顶部48行，按光栅顺序嵌入时负载头整个被破坏
+			damaged := image.NewRGBA(encoded.Bounds())
+			draw.Draw(damaged, damaged.Bounds(), encoded, image.Point{}, draw.Src)
+			draw.Draw(damaged, image.Rect(0, 0, 512, 48), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+			f, err := ExtractFile(s, damaged, opts)
+			if err != nil {
+				t.Fatalf("ExtractFile() error = %v", err)
+			}
+			if !bytes.Equal(f.Data, data) || f.Corrected == 0 {
+				t.Errorf("ExtractFile() = %q, corrected %d; want data restored with corrections", f.Data, f.Corrected)
+			}
+		})
+	}
+}
+
+func TestInterleave_ReadsSequentialImages(t *testing.T) {
+	// 交织之前的版本按光栅顺序嵌入，参数为0，提取时应自动识别
+	for _, c := range []carrier{
+		&DCTSteganography{blockSize: 8, sequential: true},
+		&DWTSteganography{sequential: true},
+	} {
+		s := c.(Steganographer)
+		t.Run(s.Name(), func(t *testing.T) {
+			encoded, err := Embed(s, newGradientImage(256), []byte("raster order"), nil)
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+			stego, _ := New(s.Name())
+			if got, err := stego.ExtractText(encoded); err != nil || got != "raster order" {
+				t.Errorf("ExtractText() = %q, %v; want %q", got, err, "raster order")
+			}
+		})
+	}
+}
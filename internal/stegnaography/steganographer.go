@@ -0,0 +1,75 @@
+package steganography
+
+import (
+	"fmt"
+	"image"
+)
+
+// Steganographer 是所有隐写算法的统一接口
+type Steganographer interface {
+	// Name 返回算法名称，如 "LSB"
+	Name() string
+	// EmbedText 将文本嵌入图像，返回新的图像
+	EmbedText(img image.Image, text string) (image.Image, error)
+	// ExtractText 从图像中提取文本
+	ExtractText(img image.Image) (string, error)
+	// EmbedBytes 将任意二进制数据嵌入图像，数据前写入负载头
+	EmbedBytes(img image.Image, data []byte) (image.Image, error)
+	// ExtractBytes 从图像中提取二进制数据
+	ExtractBytes(img image.Image) ([]byte, error)
+	// Capacity 返回给定尺寸的图像最多可嵌入的字节数
+	Capacity(bounds image.Rectangle) int
+	// Constraints 返回算法对图像尺寸的要求
+	Constraints() Constraints
+}
+
+// JPEGSteganographer 是直接修改JPEG量化系数的隐写算法，输入输出都是JPEG文件的数据，
+// 不经过像素域。负载格式与其他算法相同，加密、压缩、纠错和文件元数据同样可用；
+// opts中的Pad不起作用
+type JPEGSteganographer interface {
+	// Name 返回算法名称，如 "F5"
+	Name() string
+	// Embed 将数据嵌入JPEG，返回新的JPEG数据
+	Embed(jpeg, data []byte, opts *Options) ([]byte, error)
+	// EmbedFile 将文件及其元数据嵌入JPEG，返回新的JPEG数据
+	EmbedFile(jpeg []byte, f *File, opts *Options) ([]byte, error)
+	// ExtractFile 从JPEG中提取文件，嵌入的是普通数据时Name和ContentType为空
+	ExtractFile(jpeg []byte, opts *Options) (*File, error)
+	// Inspect 读取并校验负载头，不解密负载，opts中只有Key会被使用
+	Inspect(jpeg []byte, opts *Options) (*Header, error)
+	// Capacity 返回JPEG预计可嵌入的字节数（已扣除负载头）
+	Capacity(jpeg []byte) (int, error)
+}
+
+// Constraints 描述算法对载体图像尺寸的要求
+type Constraints struct {
+	// BlockSize 要求宽高均为其整数倍，0或1表示无限制
+	BlockSize int
+}
+
+// Check 检查图像尺寸是否满足要求
+func (c Constraints) Check(bounds image.Rectangle) error {
+	width, height := bounds.Dx(), bounds.Dy()
+	if c.BlockSize > 1 && (width%c.BlockSize != 0 || height%c.BlockSize != 0) {
+		return fmt.Errorf("图像尺寸必须是%d的倍数", c.BlockSize)
+	}
+	return nil
+}
+
+// PadSize 返回不小于给定尺寸且满足要求的最小尺寸（向上取整）
+func (c Constraints) PadSize(width, height int) (int, int) {
+	if c.BlockSize > 1 {
+		width += (c.BlockSize - width%c.BlockSize) % c.BlockSize
+		height += (c.BlockSize - height%c.BlockSize) % c.BlockSize
+	}
+	return width, height
+}
+
+// 确保所有内置算法都实现了Steganographer接口
+var (
+	_ Steganographer = (*LSB)(nil)
+	_ Steganographer = (*DCTSteganography)(nil)
+	_ Steganographer = (*DWTSteganography)(nil)
+
+	_ JPEGSteganographer = (*F5)(nil)
+)
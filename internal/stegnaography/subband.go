@@ -0,0 +1,92 @@
+package steganography
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Subband 表示DWT嵌入使用的细节子带，可以按位组合
+type Subband uint8
+
+const (
+	SubbandLH Subband = 1 << iota
+	SubbandHL
+	SubbandHH
+
+	subbandAll = SubbandLH | SubbandHL | SubbandHH
+)
+
+// 按dwtPyramid.details中的顺序排列
+var subbandNames = [...]string{"LH", "HL", "HH"}
+
+func (s Subband) String() string {
+	var names []string
+	for i, name := range subbandNames {
+		if s&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if s&^subbandAll != 0 || len(names) == 0 {
+		return fmt.Sprintf("Subband(%#x)", uint8(s))
+	}
+	return strings.Join(names, "+")
+}
+
+// ParseSubbands 解析以逗号或加号分隔的子带名称，如"hl,hh"，不区分大小写
+func ParseSubbands(spec string) (Subband, error) {
+	var s Subband
+	for _, field := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '+' }) {
+		i := indexFold(subbandNames[:], strings.TrimSpace(field))
+		if i < 0 {
+			return 0, fmt.Errorf("未知的子带: %s（可选 LH, HL, HH）", field)
+		}
+		s |= 1 << i
+	}
+	if s == 0 {
+		return 0, fmt.Errorf("没有指定子带")
+	}
+	return s, nil
+}
+
+// CoefficientSelection 决定子带中哪些系数用于嵌入
+type CoefficientSelection uint8
+
+const (
+	// SelectAll 使用子带中的全部系数
+	SelectAll CoefficientSelection = iota
+	// SelectTextured 只使用幅值不小于阈值的系数，跳过天空、背景等平坦区域中
+	// 接近0的系数，修改集中在纹理和边缘处，不易察觉。嵌入时保证携带数据的系数
+	// 留在阈值之上、途经而被跳过的系数留在阈值之下，提取时按同一阈值遍历即可
+	// 得到相同的系数序列。容量取决于图像内容，平坦的图像可能放不下
+	SelectTextured
+
+	selectionCount
+)
+
+var selectionNames = [selectionCount]string{"all", "textured"}
+
+func (s CoefficientSelection) String() string {
+	if s < selectionCount {
+		return selectionNames[s]
+	}
+	return fmt.Sprintf("CoefficientSelection(%d)", s)
+}
+
+// ParseSelection 解析系数选择方式：all, textured，不区分大小写
+func ParseSelection(name string) (CoefficientSelection, error) {
+	i := indexFold(selectionNames[:], name)
+	if i < 0 {
+		return 0, fmt.Errorf("未知的系数选择方式: %s（可选 %s）", name, strings.Join(selectionNames[:], ", "))
+	}
+	return CoefficientSelection(i), nil
+}
+
+// 返回name在names中不区分大小写的位置，不存在时返回-1
+func indexFold(names []string, name string) int {
+	for i, n := range names {
+		if strings.EqualFold(n, name) {
+			return i
+		}
+	}
+	return -1
+}
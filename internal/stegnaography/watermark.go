@@ -0,0 +1,367 @@
+package steganography
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// 用于派生水印比特位置的域分隔前缀
+const watermarkOrderDomain = "steganography-tool watermark order v1\x00"
+
+const (
+	// DefaultWatermarkStrength 是默认的水印强度（亮度单位）
+	DefaultWatermarkStrength = 8
+	// 默认分解级数
+	watermarkDefaultLevels = 2
+	// LL子带中每个水印比特至少占用的块边长
+	watermarkMinBlock = 2
+	// 幂迭代求最大奇异值的最大次数
+	watermarkPowerIterations = 50
+)
+
+// Watermark 是二值水印，例如缩小后的logo
+type Watermark struct {
+	Width, Height int
+	// Bits 按行存储，1为前景（logo中较暗的部分），0为背景
+	Bits []int
+}
+
+// WatermarkFromImage 将logo按区域平均缩放到width×height，再按亮度二值化：
+// 亮度低于128的格子为前景。透明部分视为白色背景
+func WatermarkFromImage(img image.Image, width, height int) (*Watermark, error) {
+	bounds := img.Bounds()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("水印尺寸无效: %dx%d", width, height)
+	}
+	if bounds.Dx() < width || bounds.Dy() < height {
+		return nil, fmt.Errorf("logo太小（%dx%d），无法生成%dx%d的水印", bounds.Dx(), bounds.Dy(), width, height)
+	}
+
+	mark := &Watermark{Width: width, Height: height, Bits: make([]int, width*height)}
+	for by := 0; by < height; by++ {
+		y0, y1 := bounds.Min.Y+by*bounds.Dy()/height, bounds.Min.Y+(by+1)*bounds.Dy()/height
+		for bx := 0; bx < width; bx++ {
+			x0, x1 := bounds.Min.X+bx*bounds.Dx()/width, bounds.Min.X+(bx+1)*bounds.Dx()/width
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+					luma := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+					alpha := float64(c.A) / 255
+					sum += alpha*luma + (1-alpha)*255
+				}
+			}
+			if sum/float64((y1-y0)*(x1-x0)) < 128 {
+				mark.Bits[by*width+bx] = 1
+			}
+		}
+	}
+	return mark, nil
+}
+
+// Image 将水印画成黑白图像，前景为黑色
+func (w *Watermark) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w.Width, w.Height))
+	for i, bit := range w.Bits {
+		if bit == 0 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+func (w *Watermark) validate() error {
+	if w == nil || w.Width <= 0 || w.Height <= 0 || len(w.Bits) != w.Width*w.Height {
+		return errors.New("水印无效：尺寸与比特数不一致")
+	}
+	return nil
+}
+
+// Correlation 返回两个同尺寸水印的归一化相关系数，比特按±1计算，取值-1到1：
+// 完全相同时为1，完全相反时为-1，与无关图像提取的结果约为0，
+// 标准差约为1/√比特数（32×32的水印约0.03）
+func (w *Watermark) Correlation(other *Watermark) (float64, error) {
+	if err := w.validate(); err != nil {
+		return 0, err
+	}
+	if err := other.validate(); err != nil {
+		return 0, err
+	}
+	if w.Width != other.Width || w.Height != other.Height {
+		return 0, fmt.Errorf("水印尺寸不一致: %dx%d 与 %dx%d", w.Width, w.Height, other.Width, other.Height)
+	}
+	sum := 0
+	for i, bit := range w.Bits {
+		if bit == other.Bits[i] {
+			sum++
+		} else {
+			sum--
+		}
+	}
+	return float64(sum) / float64(len(w.Bits)), nil
+}
+
+// DWTSVDWatermark 在DWT最后一级LL子带的奇异值中嵌入二值水印，用于标记
+// 公开发布的图片。LL子带按水印尺寸划分为网格，每个比特占一块，按密钥打散
+// 位置；每块最大奇异值除以块大小后近似于块的平均亮度，对它做抖动QIM。
+// 块的划分与图像尺寸成比例，平均亮度在缩放、轻度模糊和JPEG压缩后变化很小，
+// 因此比在细节系数中嵌入符号稳健得多。提取不需要原图，但只能得到水印比特，
+// 是否存在水印由与参考水印的相关系数判断，与文本消息的提取相互独立
+type DWTSVDWatermark struct {
+	// Wavelet 为使用的小波，默认为Haar
+	Wavelet Wavelet
+	// Levels 为分解级数（1–4），0视为2
+	Levels int
+	// Strength 为QIM步长，以像素亮度为单位，0视为DefaultWatermarkStrength。
+	// 强度越大越能经受有损处理，每块亮度的改动也越大，最多为一个步长
+	Strength float64
+	// Key 决定水印比特所在的块和抖动量，检测时需提供相同密钥
+	Key string
+}
+
+// NewDWTSVDWatermark 创建使用默认参数的水印
+func NewDWTSVDWatermark() *DWTSVDWatermark {
+	return &DWTSVDWatermark{}
+}
+
+func (s *DWTSVDWatermark) levels() int {
+	if s.Levels == 0 {
+		return watermarkDefaultLevels
+	}
+	return s.Levels
+}
+
+func (s *DWTSVDWatermark) strength() float64 {
+	if s.Strength == 0 {
+		return DefaultWatermarkStrength
+	}
+	return s.Strength
+}
+
+// 使用DWTSteganography的变换
+func (s *DWTSVDWatermark) transform() *DWTSteganography {
+	return &DWTSteganography{Wavelet: s.Wavelet, Levels: s.levels()}
+}
+
+func (s *DWTSVDWatermark) validate() error {
+	if s.strength() < 0 || math.IsNaN(s.strength()) {
+		return fmt.Errorf("水印强度无效: %v", s.Strength)
+	}
+	return s.transform().validate()
+}
+
+// watermarkBlock 是LL子带中一个水印比特占用的矩形区域
+type watermarkBlock struct {
+	r0, r1, c0, c1 int
+}
+
+// 将原始区域对应的LL子带按水印尺寸划分网格，按行返回各块。
+// 返回值的第k项为第k个水印比特使用的块，顺序按密钥打散
+func (s *DWTSVDWatermark) blocks(region image.Rectangle, width, height int) ([]watermarkBlock, error) {
+	rows, cols := region.Dy()>>s.levels(), region.Dx()>>s.levels()
+	if rows/height < watermarkMinBlock || cols/width < watermarkMinBlock {
+		return nil, fmt.Errorf("图像太小，无法容纳%dx%d的水印", width, height)
+	}
+	n := width * height
+	order := shuffledOrder(sha256.Sum256([]byte(watermarkOrderDomain+s.Key)), n, n)
+	blocks := make([]watermarkBlock, n)
+	for k, cell := range order {
+		by, bx := cell/width, cell%width
+		blocks[k] = watermarkBlock{
+			r0: by * rows / height, r1: (by + 1) * rows / height,
+			c0: bx * cols / width, c1: (bx + 1) * cols / width,
+		}
+	}
+	return blocks, nil
+}
+
+// 分解图像的亮度，尺寸不满足变换要求时按边缘填充，返回颜色平面和分解结果
+func (s *DWTSVDWatermark) analyze(img image.Image) (*colorPlanes, *dwtPyramid) {
+	d := s.transform()
+	bounds := img.Bounds()
+	width, height := d.Constraints().PadSize(bounds.Dx(), bounds.Dy())
+	if width != bounds.Dx() || height != bounds.Dy() {
+		img = padImage(img, width, height, PadEdge)
+	}
+	planes := splitPlanes(img)
+	return planes, d.decompose(lumaMatrix(planes, width, height))
+}
+
+// 块的最大奇异值换算到像素亮度的比例：LL子带每级增益为2，
+// 奇异值再按块的元素个数开方归一化
+func (s *DWTSVDWatermark) scale(b watermarkBlock) float64 {
+	return math.Ldexp(math.Sqrt(float64((b.r1-b.r0)*(b.c1-b.c0))), s.levels())
+}
+
+// Embed 在图像中嵌入水印，只修改亮度，输出与输入尺寸相同
+func (s *DWTSVDWatermark) Embed(img image.Image, mark *Watermark) (image.Image, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if err := mark.validate(); err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	blocks, err := s.blocks(bounds, mark.Width, mark.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	d := s.transform()
+	planes, p := s.analyze(img)
+	step := s.strength()
+	dither := qimDither(s.Key, len(blocks), step)
+	targets := make([]float64, len(blocks))
+	for k, b := range blocks {
+		sigma, u, v := largestSingular(p.ll, b)
+		value := sigma / s.scale(b)
+		target := qimEmbed(value-dither[k], step, mark.Bits[k]) + dither[k]
+		// 亮度不能超出[0,255]，移到同一比特的另一个格点
+		if target < step/2 {
+			target += 2 * step
+		} else if target > 255-step/2 {
+			target -= 2 * step
+		}
+		addRankOne(p.ll, b, (target-value)*s.scale(b), u, v)
+		targets[k] = target
+	}
+	setLumaMatrix(planes, d.reconstruct(p))
+
+	// 取整和截断会使奇异值偏离目标，偏差过大时反向补偿后重新逆变换
+	for pass := 0; pass < maxCorrectionPasses; pass++ {
+		_, actual := s.analyze(planes.toImage())
+		done := true
+		for k, b := range blocks {
+			sigma, _, _ := largestSingular(actual.ll, b)
+			if diff := targets[k] - sigma/s.scale(b); math.Abs(diff) >= step/4 {
+				_, u, v := largestSingular(p.ll, b)
+				addRankOne(p.ll, b, diff*s.scale(b), u, v)
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+		setLumaMatrix(planes, d.reconstruct(p))
+	}
+
+	return cropImage(planes.toImage(), bounds), nil
+}
+
+// Extract 读取width×height的水印，不需要原图。图像可以经过缩放，
+// 块按与尺寸成比例的位置划分
+func (s *DWTSVDWatermark) Extract(img image.Image, width, height int) (*Watermark, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("水印尺寸无效: %dx%d", width, height)
+	}
+	blocks, err := s.blocks(img.Bounds(), width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	_, p := s.analyze(img)
+	step := s.strength()
+	dither := qimDither(s.Key, len(blocks), step)
+	mark := &Watermark{Width: width, Height: height, Bits: make([]int, len(blocks))}
+	for k, b := range blocks {
+		sigma, _, _ := largestSingular(p.ll, b)
+		mark.Bits[k] = qimExtract(sigma/s.scale(b)-dither[k], step)
+	}
+	return mark, nil
+}
+
+// Detect 读取与reference同尺寸的水印，返回提取结果及其与reference的相关系数。
+// 相关系数明显大于0（例如32×32的水印超过0.3）时可以认为图像带有该水印
+func (s *DWTSVDWatermark) Detect(img image.Image, reference *Watermark) (*Watermark, float64, error) {
+	if err := reference.validate(); err != nil {
+		return nil, 0, err
+	}
+	mark, err := s.Extract(img, reference.Width, reference.Height)
+	if err != nil {
+		return nil, 0, err
+	}
+	score, err := mark.Correlation(reference)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mark, score, nil
+}
+
+// 用幂迭代求块的最大奇异值及对应的左右奇异向量。图像块的最大奇异值
+// 远大于其余奇异值，迭代很快收敛。全零的块返回0和均匀向量
+func largestSingular(m [][]float64, b watermarkBlock) (float64, []float64, []float64) {
+	rows, cols := b.r1-b.r0, b.c1-b.c0
+	u := make([]float64, rows)
+	v := make([]float64, cols)
+	for j := range v {
+		v[j] = 1 / math.Sqrt(float64(cols))
+	}
+
+	sigma := 0.0
+	for iter := 0; iter < watermarkPowerIterations; iter++ {
+		// u = A·v / |A·v|
+		for i := range u {
+			u[i] = 0
+			for j, vj := range v {
+				u[i] += m[b.r0+i][b.c0+j] * vj
+			}
+		}
+		if normalize(u) == 0 {
+			break
+		}
+		// v = Aᵀ·u / |Aᵀ·u|，模长即奇异值
+		for j := range v {
+			v[j] = 0
+			for i, ui := range u {
+				v[j] += m[b.r0+i][b.c0+j] * ui
+			}
+		}
+		next := normalize(v)
+		if math.Abs(next-sigma) <= 1e-12*next {
+			sigma = next
+			break
+		}
+		sigma = next
+	}
+	if sigma == 0 {
+		for i := range u {
+			u[i] = 1 / math.Sqrt(float64(rows))
+		}
+		for j := range v {
+			v[j] = 1 / math.Sqrt(float64(cols))
+		}
+	}
+	return sigma, u, v
+}
+
+// 将向量归一化，返回原来的模长。模长为0时不修改
+func normalize(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	norm := math.Sqrt(sum)
+	if norm > 0 {
+		for i := range x {
+			x[i] /= norm
+		}
+	}
+	return norm
+}
+
+// 块加上delta·u·vᵀ，u和v为最大奇异值对应的奇异向量时，只改变最大奇异值
+func addRankOne(m [][]float64, b watermarkBlock, delta float64, u, v []float64) {
+	for i, ui := range u {
+		row := m[b.r0+i][b.c0:b.c1]
+		for j, vj := range v {
+			row[j] += delta * ui * vj
+		}
+	}
+}
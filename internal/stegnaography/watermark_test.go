@@ -0,0 +1,184 @@
+package steganography
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// 32×32的圆环logo
+func newRingWatermark() *Watermark {
+	logo := image.NewGray(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			r2 := (x-64)*(x-64) + (y-64)*(y-64)
+			if r2 < 50*50 && r2 > 30*30 {
+				logo.SetGray(x, y, color.Gray{0})
+			} else {
+				logo.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	mark, err := WatermarkFromImage(logo, 32, 32)
+	if err != nil {
+		panic(err)
+	}
+	return mark
+}
+
+// 按区域平均缩放图像，放大时退化为最近邻
+func resizeImage(img image.Image, width, height int) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			var sr, sg, sb, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sr, sg, sb, n = sr+r>>8, sg+g>>8, sb+b>>8, n+1
+				}
+			}
+			out.Set(x, y, color.RGBA{uint8(sr / n), uint8(sg / n), uint8(sb / n), 255})
+		}
+	}
+	return out
+}
+
+// 3×3均值模糊
+func blurImage(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var sr, sg, sb, n uint32
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if !(image.Point{x + dx, y + dy}).In(bounds) {
+						continue
+					}
+					r, g, b, _ := img.At(x+dx, y+dy).RGBA()
+					sr, sg, sb, n = sr+r>>8, sg+g>>8, sb+b>>8, n+1
+				}
+			}
+			out.Set(x, y, color.RGBA{uint8(sr / n), uint8(sg / n), uint8(sb / n), 255})
+		}
+	}
+	return out
+}
+
+func reencodeJPEG(t *testing.T, img image.Image, quality int) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("jpeg.Encode() error = %v", err)
+	}
+	decoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("jpeg.Decode() error = %v", err)
+	}
+	return decoded
+}
+
+func TestWatermark_FromImage(t *testing.T) {
+	mark := newRingWatermark()
+	// 圆环中心和角落是背景，环上是前景
+	if mark.Bits[16*32+16] != 0 || mark.Bits[0] != 0 || mark.Bits[16*32+6] != 1 {
+		t.Errorf("WatermarkFromImage() center, corner, ring = %d, %d, %d; want 0, 0, 1",
+			mark.Bits[16*32+16], mark.Bits[0], mark.Bits[16*32+6])
+	}
+
+	again, err := WatermarkFromImage(mark.Image(), 32, 32)
+	if err != nil {
+		t.Fatalf("WatermarkFromImage() error = %v", err)
+	}
+	if score, err := again.Correlation(mark); err != nil || score != 1 {
+		t.Errorf("Correlation() after Image() = %v, %v; want 1", score, err)
+	}
+
+	if _, err := WatermarkFromImage(mark.Image(), 64, 64); err == nil {
+		t.Error("WatermarkFromImage() larger than logo succeeded")
+	}
+	if _, err := mark.Correlation(&Watermark{Width: 16, Height: 16, Bits: make([]int, 256)}); err == nil {
+		t.Error("Correlation() with different size succeeded")
+	}
+}
+
+func TestDWTSVDWatermark_Robustness(t *testing.T) {
+	cover := addNoise(newGradientImage(512), 20, 1)
+	mark := newRingWatermark()
+
+	for _, w := range []Wavelet{WaveletHaar, WaveletDaubechies4, WaveletCDF97} {
+		s := &DWTSVDWatermark{Wavelet: w, Key: "publisher"}
+		marked, err := s.Embed(cover, mark)
+		if err != nil {
+			t.Fatalf("%s: Embed() error = %v", w, err)
+		}
+		if mse := meanSquaredError(cover, marked); mse > 30 {
+			t.Errorf("%s: MSE = %.1f; want <= 30", w, mse)
+		}
+
+		attacks := []struct {
+			name string
+			img  image.Image
+			min  float64
+		}{
+			{"原图", marked, 1},
+			{"缩小一半", resizeImage(marked, 256, 256), 0.8},
+			{"缩放到0.75", resizeImage(marked, 384, 384), 0.8},
+			{"放大1.5倍", resizeImage(marked, 768, 768), 0.8},
+			{"均值模糊", blurImage(marked), 0.8},
+			{"JPEG质量75", reencodeJPEG(t, marked, 75), 0.8},
+		}
+		for _, a := range attacks {
+			_, score, err := s.Detect(a.img, mark)
+			if err != nil {
+				t.Fatalf("%s %s: Detect() error = %v", w, a.name, err)
+			}
+			if score < a.min {
+				t.Errorf("%s %s: correlation = %.3f; want >= %.2f", w, a.name, score, a.min)
+			}
+		}
+	}
+}
+
+func TestDWTSVDWatermark_Detect(t *testing.T) {
+	cover := addNoise(newGradientImage(512), 20, 2)
+	mark := newRingWatermark()
+	s := &DWTSVDWatermark{Key: "publisher"}
+	marked, err := s.Embed(cover, mark)
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	// 未加水印的图像、错误的密钥都只能得到与参考水印无关的比特
+	if _, score, err := s.Detect(cover, mark); err != nil || score > 0.2 {
+		t.Errorf("Detect() on unmarked image = %.3f, %v; want <= 0.2", score, err)
+	}
+	wrongKey := &DWTSVDWatermark{Key: "someone else"}
+	if _, score, err := wrongKey.Detect(marked, mark); err != nil || score > 0.2 {
+		t.Errorf("Detect() with wrong key = %.3f, %v; want <= 0.2", score, err)
+	}
+
+	// 提取结果可以还原logo
+	got, err := s.Extract(marked, 32, 32)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if score, _ := got.Correlation(mark); score != 1 {
+		t.Errorf("Extract() correlation = %.3f; want 1", score)
+	}
+
+	if _, err := s.Embed(newGradientImage(64), mark); err == nil {
+		t.Error("Embed() into image too small for the watermark succeeded")
+	}
+	if _, err := (&DWTSVDWatermark{Levels: 5}).Embed(cover, mark); err == nil {
+		t.Error("Embed() with 5 levels succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package steganography
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Wavelet 表示DWT使用的小波
+type Wavelet uint8
+
+const (
+	// WaveletHaar 是Haar小波，不需要边界延拓
+	WaveletHaar Wavelet = iota
+	// WaveletDaubechies4 是4抽头的Daubechies正交小波，边界按周期延拓，
+	// 保持变换正交
+	WaveletDaubechies4
+	// WaveletCDF97 是JPEG 2000有损模式使用的CDF 9/7双正交小波，
+	// 边界按对称（不重复端点）延拓，平滑区域的边缘不会产生假细节
+	WaveletCDF97
+
+	waveletCount
+)
+
+var waveletNames = [waveletCount]string{"haar", "db4", "cdf97"}
+
+func (w Wavelet) String() string {
+	if w < waveletCount {
+		return waveletNames[w]
+	}
+	return fmt.Sprintf("Wavelet(%d)", w)
+}
+
+// ParseWavelet 解析小波名称：haar, db4, cdf97，不区分大小写
+func ParseWavelet(name string) (Wavelet, error) {
+	for w, n := range waveletNames {
+		if strings.EqualFold(name, n) {
+			return Wavelet(w), nil
+		}
+	}
+	return 0, fmt.Errorf("未知的小波: %s（可选 %s）", name, strings.Join(waveletNames[:], ", "))
+}
+
+// 一维正变换，data的长度必须为偶数。Daubechies-4和CDF 9/7用提升格式实现，
+// 逆变换按相反顺序撤销各提升步骤，因此在任何长度下都能精确重建。
+// 各小波的低通增益均为√2，与Haar相同，系数的幅值可以直接比较
+func (w Wavelet) forward(data []float64) ([]float64, []float64) {
+	n := len(data) / 2
+	s := make([]float64, n)
+	d := make([]float64, n)
+	for i := 0; i < n; i++ {
+		s[i], d[i] = data[2*i], data[2*i+1]
+	}
+
+	switch w {
+	case WaveletDaubechies4:
+		for i := range s {
+			s[i] += math.Sqrt(3) * d[i]
+		}
+		for i := range d {
+			d[i] -= math.Sqrt(3)/4*s[i] + (math.Sqrt(3)-2)/4*s[(i-1+n)%n]
+		}
+		for i := range s {
+			s[i] -= d[(i+1)%n]
+		}
+		for i := range s {
+			s[i] *= d4ScaleLow
+			d[i] *= d4ScaleHigh
+		}
+	case WaveletCDF97:
+		liftOdd(s, d, cdf97Alpha)
+		liftEven(s, d, cdf97Beta)
+		liftOdd(s, d, cdf97Gamma)
+		liftEven(s, d, cdf97Delta)
+		for i := range s {
+			s[i] *= cdf97K
+			d[i] /= cdf97K
+		}
+	default:
+		for i := range s {
+			s[i], d[i] = (s[i]+d[i])/math.Sqrt(2), (s[i]-d[i])/math.Sqrt(2)
+		}
+	}
+	return s, d
+}
+
+// 一维逆变换
+func (w Wavelet) inverse(approx, detail []float64) []float64 {
+	n := len(approx)
+	s := make([]float64, n)
+	d := make([]float64, n)
+	copy(s, approx)
+	copy(d, detail)
+
+	switch w {
+	case WaveletDaubechies4:
+		for i := range s {
+			s[i] /= d4ScaleLow
+			d[i] /= d4ScaleHigh
+		}
+		for i := range s {
+			s[i] += d[(i+1)%n]
+		}
+		for i := range d {
+			d[i] += math.Sqrt(3)/4*s[i] + (math.Sqrt(3)-2)/4*s[(i-1+n)%n]
+		}
+		for i := range s {
+			s[i] -= math.Sqrt(3) * d[i]
+		}
+	case WaveletCDF97:
+		for i := range s {
+			s[i] /= cdf97K
+			d[i] *= cdf97K
+		}
+		liftEven(s, d, -cdf97Delta)
+		liftOdd(s, d, -cdf97Gamma)
+		liftEven(s, d, -cdf97Beta)
+		liftOdd(s, d, -cdf97Alpha)
+	default:
+		for i := range s {
+			s[i], d[i] = (s[i]+d[i])/math.Sqrt(2), (s[i]-d[i])/math.Sqrt(2)
+		}
+	}
+
+	result := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		result[2*i], result[2*i+1] = s[i], d[i]
+	}
+	return result
+}
+
+// Daubechies-4提升格式最后的缩放系数
+var (
+	d4ScaleLow  = (math.Sqrt(3) - 1) / math.Sqrt(2)
+	d4ScaleHigh = (math.Sqrt(3) + 1) / math.Sqrt(2)
+)
+
+// CDF 9/7的提升系数（Daubechies & Sweldens, 1998）
+const (
+	cdf97Alpha = -1.586134342059924
+	cdf97Beta  = -0.052980118572961
+	cdf97Gamma = 0.882911075530934
+	cdf97Delta = 0.443506852043971
+	cdf97K     = 1.149604398860241
+)
+
+// 奇数位置加上相邻两个偶数位置之和的c倍，右边界对称延拓：x[2n] = x[2n-2]
+func liftOdd(s, d []float64, c float64) {
+	for i := range d {
+		d[i] += c * (s[i] + s[min(i+1, len(s)-1)])
+	}
+}
+
+// 偶数位置加上相邻两个奇数位置之和的c倍，左边界对称延拓：x[-1] = x[1]
+func liftEven(s, d []float64, c float64) {
+	for i := range s {
+		s[i] += c * (d[max(i-1, 0)] + d[i])
+	}
+}
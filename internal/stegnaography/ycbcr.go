@@ -0,0 +1,93 @@
+package steganography
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// colorPlanes 以浮点数保存图像的YCbCr平面和Alpha通道。
+// 变换域算法只修改亮度平面Y，还原时保留原有的色度和透明度
+type colorPlanes struct {
+	bounds image.Rectangle
+	// 按行存储，下标为 y*width+x（相对于bounds.Min）
+	y, cb, cr []float64
+	a         []uint8
+}
+
+// 将图像拆分为YCbCr平面（JPEG使用的全范围转换）
+func splitPlanes(img image.Image) *colorPlanes {
+	bounds := img.Bounds()
+	n := bounds.Dx() * bounds.Dy()
+	p := &colorPlanes{
+		bounds: bounds,
+		y:      make([]float64, n),
+		cb:     make([]float64, n),
+		cr:     make([]float64, n),
+		a:      make([]uint8, n),
+	}
+
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+			p.y[i] = 0.299*r + 0.587*g + 0.114*b
+			p.cb[i] = 128 - 0.168736*r - 0.331264*g + 0.5*b
+			p.cr[i] = 128 + 0.5*r - 0.418688*g - 0.081312*b
+			p.a[i] = c.A
+			i++
+		}
+	}
+	return p
+}
+
+// 只计算亮度平面，用于提取
+func lumaPlane(img image.Image) *colorPlanes {
+	bounds := img.Bounds()
+	p := &colorPlanes{
+		bounds: bounds,
+		y:      make([]float64, bounds.Dx()*bounds.Dy()),
+	}
+
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			p.y[i] = 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			i++
+		}
+	}
+	return p
+}
+
+// luma 返回相对坐标(x, y)处的亮度
+func (p *colorPlanes) luma(x, y int) float64 {
+	return p.y[y*p.bounds.Dx()+x]
+}
+
+// setLuma 设置相对坐标(x, y)处的亮度
+func (p *colorPlanes) setLuma(x, y int, v float64) {
+	p.y[y*p.bounds.Dx()+x] = v
+}
+
+// 由YCbCr平面还原彩色图像，未修改的像素可以精确还原
+func (p *colorPlanes) toImage() *image.NRGBA {
+	out := image.NewNRGBA(p.bounds)
+	width := p.bounds.Dx()
+	for i := range p.y {
+		y, cb, cr := p.y[i], p.cb[i]-128, p.cr[i]-128
+		out.SetNRGBA(p.bounds.Min.X+i%width, p.bounds.Min.Y+i/width, color.NRGBA{
+			R: clampUint8(y + 1.402*cr),
+			G: clampUint8(y - 0.344136*cb - 0.714136*cr),
+			B: clampUint8(y + 1.772*cb),
+			A: p.a[i],
+		})
+	}
+	return out
+}
+
+// 四舍五入并限制在[0,255]范围内
+func clampUint8(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
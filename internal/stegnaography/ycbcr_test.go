@@ -0,0 +1,83 @@
+package steganography
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestColorPlanes_RoundTrip(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(3, 5, 67, 69))
+	for y := 5; y < 69; y++ {
+		for x := 3; x < 67; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 37), uint8(y * 91), uint8(x * y), uint8(128 + x)})
+		}
+	}
+
+	// 未修改亮度时应能精确还原原图
+	restored := splitPlanes(img).toImage()
+	if restored.Bounds() != img.Bounds() {
+		t.Fatalf("toImage() bounds = %v; want %v", restored.Bounds(), img.Bounds())
+	}
+	for y := 5; y < 69; y++ {
+		for x := 3; x < 67; x++ {
+			if got, want := restored.NRGBAAt(x, y), img.NRGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d) = %v; want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestTransformEmbedding_PreservesColor(t *testing.T) {
+	cover := newGradientImage(256)
+	text := "keep the colors"
+
+	tests := []struct {
+		stego Steganographer
+		cell  int // 每个比特影响的像素块边长
+	}{
+		{NewDCTSteganography(), 8},
+		{NewDWTSteganography(), 2},
+	}
+	for _, tt := range tests {
+		stego := tt.stego
+		t.Run(stego.Name(), func(t *testing.T) {
+			encodedImg, err := stego.EmbedText(cover, text)
+			if err != nil {
+				t.Fatalf("EmbedText() error = %v", err)
+			}
+			if extracted, err := stego.ExtractText(encodedImg); err != nil || extracted != text {
+				t.Fatalf("ExtractText() = %q, %v; want %q", extracted, err, text)
+			}
+
+			before := splitPlanes(cover)
+			after := splitPlanes(encodedImg)
+			maxChromaDiff := 0.0
+			for i := range before.y {
+				maxChromaDiff = math.Max(maxChromaDiff, math.Abs(before.cb[i]-after.cb[i]))
+				maxChromaDiff = math.Max(maxChromaDiff, math.Abs(before.cr[i]-after.cr[i]))
+				if before.a[i] != after.a[i] {
+					t.Fatalf("alpha changed at %d: %d -> %d", i, before.a[i], after.a[i])
+				}
+			}
+			// 只允许由取整和饱和截断带来的少量色度偏差
+			if maxChromaDiff > 16 {
+				t.Errorf("chroma changed by up to %.1f; want colors preserved", maxChromaDiff)
+			}
+
+			// 交织后嵌入位置分散在整张图像中，但未使用的块应保持原样
+			changed := 0
+			for y := 0; y < 256; y++ {
+				for x := 0; x < 256; x++ {
+					if encodedImg.At(x, y) != color.NRGBAModel.Convert(cover.At(x, y)) {
+						changed++
+					}
+				}
+			}
+			if limit := (headerSize + len(text)) * 8 * tt.cell * tt.cell; changed > limit {
+				t.Errorf("%d pixels changed; want at most %d", changed, limit)
+			}
+		})
+	}
+}
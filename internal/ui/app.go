@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -12,20 +14,33 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	steganography "steganography-tool/internal/stegnaography"
 )
 
 type SteganoUI struct {
 	window           fyne.Window
-	lsb              *steganography.LSB
-	dct              *steganography.DCTSteganography
-	dwt              *steganography.DWTSteganography
 	imageView        *canvas.Image
 	textInput        *widget.Entry
 	resultText       *widget.RichText
-	algorithm        *widget.Select // 新增：算法选择下拉框
-	textLength       *widget.Label  // 新增：文本长度显示
-	currentImageSize image.Point    // 新增：存储当前图片尺寸
+	algorithm        *widget.Select      // 新增：算法选择下拉框
+	textLength       *widget.Label       // 新增：文本长度显示
+	passphrase       *widget.Entry       // 加密口令，留空则不加密
+	compress         *widget.Check       // 嵌入前压缩
+	ecc              *widget.Select      // 纠错等级
+	currentImageSize image.Point         // 新增：存储当前图片尺寸
+	imageData        []byte              // 当前图片的原始数据，F5等算法直接修改其中的JPEG系数
+	file             *steganography.File // 要隐藏的文件，为nil时隐藏输入的文本
+	fileLabel        *widget.Label       // 显示已选择的文件
+	fileSize         fileSizeCache       // 所选文件压缩后的大小
+}
+
+// fileSizeCache 缓存文件序列化并压缩后的大小。压缩整个文件较慢，
+// 只在文件或压缩选项变化时重新计算，输入口令等操作不必重新压缩
+type fileSizeCache struct {
+	file     *steganography.File
+	compress bool
+	size     int
 }
 
 func NewSteganoUI(app fyne.App) *SteganoUI {
@@ -37,15 +52,14 @@ func NewSteganoUI(app fyne.App) *SteganoUI {
 
 	ui := &SteganoUI{
 		window:     app.NewWindow("跟你说悄悄话"),
-		lsb:        steganography.NewLSB(),
-		dct:        steganography.NewDCTSteganography(),
-		dwt:        steganography.NewDWTSteganography(),
 		textInput:  widget.NewMultiLineEntry(),
 		textLength: widget.NewLabel(""), // 初始化文本长度标签
+		passphrase: widget.NewPasswordEntry(),
+		fileLabel:  widget.NewLabel(""),
 	}
 
 	// 初始化算法选择下拉框
-	ui.algorithm = widget.NewSelect([]string{"LSB", "DCT", "DWT"}, func(value string) {
+	ui.algorithm = widget.NewSelect(algorithmNames(), func(value string) {
 		// 当选择改变时更新文本长度显示
 		ui.updateTextLength()
 	})
@@ -55,6 +69,20 @@ func NewSteganoUI(app fyne.App) *SteganoUI {
 	ui.textInput.OnChanged = func(text string) {
 		ui.updateTextLength()
 	}
+	// 加密会增加负载大小，口令变化时同样需要更新
+	ui.passphrase.OnChanged = func(string) {
+		ui.updateTextLength()
+	}
+	// 长度显示的是压缩后的大小
+	ui.compress = widget.NewCheck("压缩后嵌入", func(bool) {
+		ui.updateTextLength()
+	})
+	ui.compress.SetChecked(true)
+	// 纠错码同样会增加负载大小
+	ui.ecc = widget.NewSelect(eccLevelNames, func(string) {
+		ui.updateTextLength()
+	})
+	ui.ecc.SetSelected(eccLevelNames[0])
 
 	ui.window.Resize(fyne.NewSize(800, 500))
 	ui.window.CenterOnScreen()
@@ -67,9 +95,13 @@ func NewSteganoUI(app fyne.App) *SteganoUI {
 
 // 新增：更新文本长度显示的方法
 func (s *SteganoUI) updateTextLength() {
-	text := s.textInput.Text
 	algorithm := s.algorithm.Selected
-	length := len(text)
+	var length int
+	if s.file != nil {
+		length = s.filePayloadSize(s.embedOptions())
+	} else {
+		length = steganography.PayloadSize([]byte(s.textInput.Text), s.embedOptions())
+	}
 
 	var maxLength int
 	if s.imageView != nil && s.imageView.Image != nil {
@@ -79,9 +111,6 @@ func (s *SteganoUI) updateTextLength() {
 
 		// 计算最大容量
 		maxLength = s.calculateMaxCapacity(width, height, algorithm)
-
-		// 预留20%空间给元数据和结束标记
-		maxLength = int(float64(maxLength) * 0.8)
 	} else {
 		maxLength = 0
 	}
@@ -99,30 +128,69 @@ func (s *SteganoUI) updateTextLength() {
 	}
 }
 
+// 所选文件经选项处理后占用的字节数，压缩结果按文件和压缩选项缓存
+func (s *SteganoUI) filePayloadSize(opts *steganography.Options) int {
+	if s.fileSize.file != s.file || s.fileSize.compress != opts.Compress {
+		s.fileSize = fileSizeCache{
+			file:     s.file,
+			compress: opts.Compress,
+			size:     steganography.FilePayloadSize(s.file, &steganography.Options{Compress: opts.Compress}),
+		}
+	}
+	return steganography.EncodedSize(s.fileSize.size, opts)
+}
+
+// 可选的算法：注册的像素域算法和直接处理JPEG数据的算法
+func algorithmNames() []string {
+	return append(steganography.Algorithms(), steganography.JPEGAlgorithms()...)
+}
+
+// 读取选择的图片，同时返回原始数据
+func readImage(reader io.Reader) (image.Image, []byte, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, data, nil
+}
+
+// 纠错等级的显示名称，下标与steganography.ECCLevel的取值一致
+var eccLevelNames = []string{"无", "低", "中", "高"}
+
+// 根据界面输入生成嵌入选项
+func (s *SteganoUI) embedOptions() *steganography.Options {
+	// 尺寸不满足算法要求时镜像填充，保存的图片保持原始尺寸
+	opts := &steganography.Options{Pad: steganography.PadMirror}
+	if s.compress != nil {
+		opts.Compress = s.compress.Checked
+	}
+	if s.ecc != nil {
+		opts.ECC = steganography.ECCLevel(s.ecc.SelectedIndex())
+	}
+	if s.passphrase != nil {
+		// 口令同时作为嵌入顺序密钥，使数据分散在整张图片中
+		opts.Passphrase = s.passphrase.Text
+		opts.Key = s.passphrase.Text
+	}
+	return opts
+}
+
 // 在 SteganoUI 结构体中添加一个方法来计算最大容量
 func (s *SteganoUI) calculateMaxCapacity(width, height int, algorithm string) int {
-	pixelCount := width * height
-
-	switch algorithm {
-	case "LSB":
-		// LSB算法：每个像素3个通道，每个通道可以存储1位
-		// 总比特数除以8得到字节数
-		return (pixelCount * 3) / 8
-
-	case "DCT":
-		// DCT算法：基于8x8的块
-		blocks := (width / 8) * (height / 8)
-		// 每个块可以存储1位，总比特数除以8得到字节数
-		return (blocks) / 8
-
-	case "DWT":
-		// DWT算法：使用高频分量存储数据
-		// 假设使用HL子带，大小为原图的1/4
-		return (pixelCount / 16) // 除以4获取子带大小，再除以4考虑实际可用容量
-
-	default:
+	if stego, err := steganography.NewJPEG(algorithm); err == nil {
+		// 载体不是该算法支持的JPEG时容量为0
+		capacity, _ := stego.Capacity(s.imageData)
+		return capacity
+	}
+	stego, err := steganography.New(algorithm)
+	if err != nil {
 		return 0
 	}
+	return stego.Capacity(image.Rect(0, 0, width, height))
 }
 
 func (s *SteganoUI) createUI() {
@@ -137,61 +205,16 @@ func (s *SteganoUI) createUI() {
 	s.window.CenterOnScreen()
 }
 
-// 添加图片预处理方法
-func (s *SteganoUI) preprocessImage(img image.Image, algorithm string) (image.Image, error) {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-
-	switch algorithm {
-	case "DCT":
-		// DCT需要8的倍数
-		newWidth := width - (width % 8)
-		newHeight := height - (height % 8)
-
-		if newWidth == 0 || newHeight == 0 {
-			return nil, fmt.Errorf("图片尺寸太小，需要至少8x8像素")
-		}
-
-		// 创建新图像
-		newImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-		for y := 0; y < newHeight; y++ {
-			for x := 0; x < newWidth; x++ {
-				newImg.Set(x, y, img.At(x, y))
-			}
-		}
-		return newImg, nil
-
-	case "DWT":
-		// DWT需要2的幂
-		newWidth := nearestPowerOfTwo(width)
-		newHeight := nearestPowerOfTwo(height)
-
-		if newWidth == 0 || newHeight == 0 {
-			return nil, fmt.Errorf("图片尺寸太小，需要至少2x2像素")
-		}
-
-		// 创建新图像
-		newImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-		for y := 0; y < newHeight; y++ {
-			for x := 0; x < newWidth; x++ {
-				newImg.Set(x, y, img.At(x, y))
-			}
-		}
-		return newImg, nil
-
-	default:
-		// LSB不需要特殊处理
-		return img, nil
+// 使用指定算法从图片中提取文本或文件，直接处理JPEG数据的算法从原始数据中提取
+func (s *SteganoUI) extractFile(img image.Image, data []byte, algorithm string, opts *steganography.Options) (*steganography.File, error) {
+	if stego, err := steganography.NewJPEG(algorithm); err == nil {
+		return stego.ExtractFile(data, opts)
 	}
-}
-
-// 辅助函数：找到最近的2的幂（向下取整）
-func nearestPowerOfTwo(n int) int {
-	power := 1
-	for power*2 <= n {
-		power *= 2
+	stego, err := steganography.New(algorithm)
+	if err != nil {
+		return nil, err
 	}
-	return power
+	return steganography.ExtractFile(stego, img, opts)
 }
 
 func (s *SteganoUI) createEncryptTab() fyne.CanvasObject {
@@ -222,12 +245,13 @@ func (s *SteganoUI) createEncryptTab() fyne.CanvasObject {
 						return
 					}
 
-					originalImg, _, err := image.Decode(reader)
+					originalImg, data, err := readImage(reader)
 					if err != nil {
 						dialog.ShowError(fmt.Errorf("无法加载图片: %v", err), s.window)
 						return
 					}
 					defer reader.Close()
+					s.imageData = data
 
 					// 更新当前图片尺寸
 					bounds := originalImg.Bounds()
@@ -261,9 +285,15 @@ func (s *SteganoUI) createEncryptTab() fyne.CanvasObject {
 		container.NewVBox(
 			s.algorithm,
 			s.textLength,
+			widget.NewForm(widget.NewFormItem("口令", s.passphrase)),
+			s.compress,
+			widget.NewForm(widget.NewFormItem("纠错", s.ecc)),
+			widget.NewButtonWithIcon("批量处理", theme.FolderIcon(), s.showBatchDialog),
 		),
 	)
 
+	s.passphrase.SetPlaceHolder("留空则不加密")
+
 	// 创建文本输入区
 	s.textInput.SetPlaceHolder("在此输入要隐藏的文本...")
 	s.textInput.MultiLine = true
@@ -275,50 +305,32 @@ func (s *SteganoUI) createEncryptTab() fyne.CanvasObject {
 		"文本输入",
 		container.NewVBox(
 			s.textInput,
+			container.NewHBox(
+				widget.NewButtonWithIcon("选择文件", theme.FileIcon(), s.selectFile),
+				widget.NewButtonWithIcon("清除文件", theme.ContentClearIcon(), s.clearFile),
+				s.fileLabel,
+			),
 			widget.NewButtonWithIcon("加密并保存", theme.DocumentSaveIcon(), func() {
 				if s.imageView.Image == nil {
 					dialog.ShowError(fmt.Errorf("请先选择图片"), s.window)
 					return
 				}
-				if s.textInput.Text == "" {
-					dialog.ShowError(fmt.Errorf("请输入要隐藏的文本"), s.window)
+				if s.file == nil && s.textInput.Text == "" {
+					dialog.ShowError(fmt.Errorf("请输入要隐藏的文本或选择文件"), s.window)
 					return
 				}
 
-				var encodedImg image.Image
-				var err error
-
-				// 预处理图片
-				processedImg, err := s.preprocessImage(s.imageView.Image, s.algorithm.Selected)
-				if err != nil {
-					dialog.ShowError(err, s.window)
-					return
-				}
-
-				// 如果图片尺寸发生变化，显示提示
-				// if processedImg.Bounds().Dx() != s.imageView.Image.Bounds().Dx() ||
-				// 	processedImg.Bounds().Dy() != s.imageView.Image.Bounds().Dy() {
-				// 	dialog.ShowInformation("提示", fmt.Sprintf(
-				// 		"图片已被裁剪至 %dx%d 以适应算法要求",
-				// 		processedImg.Bounds().Dx(),
-				// 		processedImg.Bounds().Dy(),
-				// 	), s.window)
-				// }
-
-				// 根据选择的算法执行相应的嵌入操作
-				switch s.algorithm.Selected {
-				case "LSB":
-					encodedImg, err = s.lsb.EmbedText(processedImg, s.textInput.Text)
-				case "DCT":
-					encodedImg, err = s.dct.EmbedText(processedImg, s.textInput.Text)
-				case "DWT":
-					encodedImg, err = s.dwt.EmbedText(processedImg, s.textInput.Text)
-				}
-
-				if err != nil {
+				// 根据选择的算法执行相应的嵌入操作，尺寸不满足要求时自动填充。
+				// 直接处理JPEG数据的算法结果是JPEG，其他算法的结果保存为PNG
+				var encoded bytes.Buffer
+				if err := s.embed(&encoded); err != nil {
 					dialog.ShowError(fmt.Errorf("加密失败: %v", err), s.window)
 					return
 				}
+				fileName := "encoded_image.png"
+				if _, err := steganography.NewJPEG(s.algorithm.Selected); err == nil {
+					fileName = "encoded_image.jpg"
+				}
 
 				fd := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
 					if err != nil {
@@ -330,14 +342,13 @@ func (s *SteganoUI) createEncryptTab() fyne.CanvasObject {
 					}
 					defer writer.Close()
 
-					err = png.Encode(writer, encodedImg)
-					if err != nil {
+					if _, err := writer.Write(encoded.Bytes()); err != nil {
 						dialog.ShowError(fmt.Errorf("保存失败: %v", err), s.window)
 						return
 					}
 					dialog.ShowInformation("成功", "图片已成功保存", s.window)
 				}, s.window)
-				fd.SetFileName("encoded_image.png")
+				fd.SetFileName(fileName)
 				fd.Show()
 			}),
 		),
@@ -356,6 +367,39 @@ func (s *SteganoUI) createEncryptTab() fyne.CanvasObject {
 	return split
 }
 
+// 按选择的算法嵌入输入的文本或文件，将结果图片写入w
+func (s *SteganoUI) embed(w io.Writer) error {
+	opts := s.embedOptions()
+	if stego, err := steganography.NewJPEG(s.algorithm.Selected); err == nil {
+		var encoded []byte
+		if s.file != nil {
+			encoded, err = stego.EmbedFile(s.imageData, s.file, opts)
+		} else {
+			encoded, err = stego.Embed(s.imageData, []byte(s.textInput.Text), opts)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(encoded)
+		return err
+	}
+
+	stego, err := steganography.New(s.algorithm.Selected)
+	if err != nil {
+		return err
+	}
+	var encoded image.Image
+	if s.file != nil {
+		encoded, err = steganography.EmbedFile(stego, s.imageView.Image, s.file, opts)
+	} else {
+		encoded, err = steganography.Embed(stego, s.imageView.Image, []byte(s.textInput.Text), opts)
+	}
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, encoded)
+}
+
 func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 	// 创建图片容器
 	imageContainer := container.NewVBox()
@@ -369,49 +413,77 @@ func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 	decryptImageView.FillMode = canvas.ImageFillContain
 	imageContainer.Add(decryptImageView)
 
-	// 保存当前图片的变量
+	// 保存当前图片及其原始数据的变量
 	var currentImg image.Image
+	var currentData []byte
 
-	// 创建算法选择
-	algorithmSelect := widget.NewSelect([]string{"LSB", "DCT", "DWT"}, func(selected string) {
-		// 当算法改变时，如果已有图片，则重新解密
-		if currentImg != nil {
-			// 预处理图片
-			processedImg, err := s.preprocessImage(currentImg, selected)
-			if err != nil {
-				dialog.ShowError(err, s.window)
-				return
-			}
+	// 创建口令输入
+	passphraseEntry := widget.NewPasswordEntry()
+	passphraseEntry.SetPlaceHolder("未加密可留空")
 
-			// 使用新算法提取文本
-			var text string
-			switch selected {
-			case "LSB":
-				text, err = s.lsb.ExtractText(processedImg)
-			case "DCT":
-				text, err = s.dct.ExtractText(processedImg)
-			case "DWT":
-				text, err = s.dwt.ExtractText(processedImg)
-			}
+	var algorithmSelect *widget.Select
 
-			if err != nil {
-				dialog.ShowError(fmt.Errorf("解密失败: %v", err), s.window)
-				return
-			}
+	// 隐藏的是文件时，提取后可以保存
+	var extracted *steganography.File
+	saveBtn := widget.NewButtonWithIcon("保存提取的文件", theme.DocumentSaveIcon(), func() {
+		if extracted != nil {
+			s.saveExtractedFile(extracted)
+		}
+	})
+	saveBtn.Disable()
 
-			// 更新文本显示
-			s.resultText.Segments = []widget.RichTextSegment{
-				&widget.TextSegment{
-					Style: widget.RichTextStyle{
-						SizeName:  theme.SizeNameText,
-						ColorName: theme.ColorNameForeground,
-						TextStyle: fyne.TextStyle{Bold: true},
-					},
-					Text: text,
+	// 提取当前图片中的文本并显示
+	decrypt := func() {
+		if currentImg == nil {
+			return
+		}
+
+		opts := &steganography.Options{Passphrase: passphraseEntry.Text, Key: passphraseEntry.Text}
+		f, err := s.extractFile(currentImg, currentData, algorithmSelect.Selected, opts)
+		if errors.Is(err, steganography.ErrPassphraseRequired) ||
+			(errors.Is(err, steganography.ErrNoHiddenData) && passphraseEntry.Text == "") {
+			// 使用口令嵌入的数据位置被打散，没有口令时找不到数据
+			dialog.ShowInformation("提示", "未找到隐藏数据，如果数据已加密，请输入口令后按回车", s.window)
+			return
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("解密失败: %v", err), s.window)
+			return
+		}
+
+		text := string(f.Data)
+		if f.Name != "" {
+			extracted = f
+			saveBtn.Enable()
+			text = "隐藏的文件：" + describeFile(f) + "\n点击\"保存提取的文件\"按钮保存"
+		} else {
+			extracted = nil
+			saveBtn.Disable()
+		}
+		if f.Corrected > 0 {
+			text += fmt.Sprintf("\n（已纠正 %d 个字节错误）", f.Corrected)
+		}
+
+		// 更新文本显示
+		s.resultText.Segments = []widget.RichTextSegment{
+			&widget.TextSegment{
+				Style: widget.RichTextStyle{
+					SizeName:  theme.SizeNameText,
+					ColorName: theme.ColorNameForeground,
+					TextStyle: fyne.TextStyle{Bold: true},
 				},
-			}
-			s.resultText.Refresh()
+				Text: text,
+			},
 		}
+		s.resultText.Refresh()
+	}
+	passphraseEntry.OnSubmitted = func(string) {
+		decrypt()
+	}
+
+	// 创建算法选择，当算法改变时，如果已有图片，则重新解密
+	algorithmSelect = widget.NewSelect(algorithmNames(), func(selected string) {
+		decrypt()
 	})
 	algorithmSelect.SetSelected("LSB")
 
@@ -425,6 +497,7 @@ func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 				widget.NewLabel("选择算法:"),
 				algorithmSelect,
 			),
+			widget.NewForm(widget.NewFormItem("口令", passphraseEntry)),
 			widget.NewButtonWithIcon("选择图片", theme.FolderOpenIcon(), func() {
 				fd := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
 					if err != nil {
@@ -435,7 +508,7 @@ func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 						return
 					}
 
-					img, _, err := image.Decode(reader)
+					img, data, err := readImage(reader)
 					if err != nil {
 						dialog.ShowError(fmt.Errorf("无法加载图片: %v", err), s.window)
 						return
@@ -444,16 +517,10 @@ func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 
 					// 保存当前图片
 					currentImg = img
-
-					// 预处理图片
-					processedImg, err := s.preprocessImage(img, algorithmSelect.Selected)
-					if err != nil {
-						dialog.ShowError(err, s.window)
-						return
-					}
+					currentData = data
 
 					// 更新图片显示
-					newImage := canvas.NewImageFromImage(processedImg)
+					newImage := canvas.NewImageFromImage(img)
 					newImage.SetMinSize(fyne.NewSize(350, 350))
 					newImage.FillMode = canvas.ImageFillContain
 					imageContainer.Remove(decryptImageView)
@@ -462,32 +529,7 @@ func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 					imageContainer.Refresh()
 
 					// 提取文本
-					var text string
-					switch algorithmSelect.Selected {
-					case "LSB":
-						text, err = s.lsb.ExtractText(processedImg)
-					case "DCT":
-						text, err = s.dct.ExtractText(processedImg)
-					case "DWT":
-						text, err = s.dwt.ExtractText(processedImg)
-					}
-
-					if err != nil {
-						dialog.ShowError(fmt.Errorf("解密失败: %v", err), s.window)
-						return
-					}
-
-					s.resultText.Segments = []widget.RichTextSegment{
-						&widget.TextSegment{
-							Style: widget.RichTextStyle{
-								SizeName:  theme.SizeNameText,
-								ColorName: theme.ColorNameForeground,
-								TextStyle: fyne.TextStyle{Bold: true},
-							},
-							Text: text,
-						},
-					}
-					s.resultText.Refresh()
+					decrypt()
 				}, s.window)
 				fd.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg"}))
 				fd.Show()
@@ -538,7 +580,7 @@ func (s *SteganoUI) createDecryptTab() fyne.CanvasObject {
 					),
 				),
 			),
-			copyBtn,
+			container.NewHBox(copyBtn, saveBtn),
 		),
 	)
 
@@ -0,0 +1,147 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"steganography-tool/internal/batch"
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+// 显示批量处理对话框：选择输入输出目录和负载模板，将负载嵌入目录中的所有图片
+func (s *SteganoUI) showBatchDialog() {
+	inputDir := widget.NewEntry()
+	inputDir.SetPlaceHolder("载体图片所在目录")
+	outputDir := widget.NewEntry()
+	outputDir.SetPlaceHolder("输出目录，保持相同的目录结构")
+
+	// 选择目录后填入输入框
+	chooseDir := func(entry *widget.Entry) *widget.Button {
+		return widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+			dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+				if err != nil {
+					dialog.ShowError(err, s.window)
+					return
+				}
+				if uri != nil {
+					entry.SetText(uri.Path())
+				}
+			}, s.window)
+		})
+	}
+
+	algorithm := widget.NewSelect(steganography.Algorithms(), nil)
+	algorithm.SetSelected(batchAlgorithm(s.algorithm.Selected))
+
+	template := widget.NewMultiLineEntry()
+	template.SetPlaceHolder("例如：ID-{name}")
+	template.SetMinRowsVisible(3)
+
+	passphrase := widget.NewPasswordEntry()
+	passphrase.SetPlaceHolder("留空则不加密")
+
+	compress := widget.NewCheck("压缩后嵌入", nil)
+	compress.SetChecked(true)
+
+	ecc := widget.NewSelect(eccLevelNames, nil)
+	ecc.SetSelected(s.ecc.Selected)
+
+	form := widget.NewForm(
+		widget.NewFormItem("输入目录", container.NewBorder(nil, nil, nil, chooseDir(inputDir), inputDir)),
+		widget.NewFormItem("输出目录", container.NewBorder(nil, nil, nil, chooseDir(outputDir), outputDir)),
+		widget.NewFormItem("算法", algorithm),
+		widget.NewFormItem("负载模板", template),
+		widget.NewFormItem("口令", passphrase),
+		widget.NewFormItem("", compress),
+		widget.NewFormItem("纠错", ecc),
+	)
+	hint := widget.NewLabel("模板占位符：{name} 文件名，{file} 含扩展名的文件名，{path} 相对路径，{index} 序号")
+	hint.Wrapping = fyne.TextWrapWord
+
+	d := dialog.NewCustomConfirm("批量处理", "开始", "取消", container.NewVBox(form, hint), func(ok bool) {
+		if !ok {
+			return
+		}
+		if inputDir.Text == "" || outputDir.Text == "" {
+			dialog.ShowError(fmt.Errorf("请选择输入和输出目录"), s.window)
+			return
+		}
+		if template.Text == "" {
+			dialog.ShowError(fmt.Errorf("请输入负载模板"), s.window)
+			return
+		}
+
+		s.runBatch(batch.Config{
+			InputDir:  inputDir.Text,
+			OutputDir: outputDir.Text,
+			Algorithm: algorithm.Selected,
+			Template:  template.Text,
+			Options: &steganography.Options{
+				Passphrase: passphrase.Text,
+				Key:        passphrase.Text,
+				Pad:        steganography.PadMirror,
+				Compress:   compress.Checked,
+				ECC:        steganography.ECCLevel(ecc.SelectedIndex()),
+			},
+		})
+	}, s.window)
+	d.Resize(fyne.NewSize(560, 0))
+	d.Show()
+}
+
+// 批量处理默认使用主窗口选择的算法。批量处理只支持像素域算法，
+// 主窗口选择的是F5等直接处理JPEG数据的算法时改用第一个像素域算法
+func batchAlgorithm(selected string) string {
+	algorithms := steganography.Algorithms()
+	if slices.Contains(algorithms, selected) {
+		return selected
+	}
+	return algorithms[0]
+}
+
+// 在后台执行批量处理，显示进度，完成后显示报告
+func (s *SteganoUI) runBatch(cfg batch.Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	progress := widget.NewProgressBar()
+	status := widget.NewLabel("正在扫描目录...")
+	progressDialog := dialog.NewCustom("正在批量处理", "停止", container.NewVBox(status, progress), s.window)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Resize(fyne.NewSize(400, 0))
+	progressDialog.Show()
+
+	cfg.Progress = func(done, total int) {
+		progress.SetValue(float64(done) / float64(total))
+		status.SetText(fmt.Sprintf("已处理 %d/%d", done, total))
+	}
+
+	go func() {
+		report, err := batch.Run(ctx, cfg)
+		progressDialog.Hide()
+		if report == nil {
+			dialog.ShowError(fmt.Errorf("批量处理失败: %v", err), s.window)
+			return
+		}
+
+		var sb strings.Builder
+		if err != nil {
+			fmt.Fprintf(&sb, "已停止: %v\n", err)
+		}
+		report.WriteTo(&sb)
+
+		result := widget.NewMultiLineEntry()
+		result.SetText(sb.String())
+		result.Wrapping = fyne.TextWrapOff
+		result.SetMinRowsVisible(12)
+		resultDialog := dialog.NewCustom("批量处理结果", "关闭", result, s.window)
+		resultDialog.Resize(fyne.NewSize(640, 0))
+		resultDialog.Show()
+	}()
+}
@@ -0,0 +1,26 @@
+package ui
+
+import (
+	"testing"
+
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+func TestBatchAlgorithm(t *testing.T) {
+	for _, name := range steganography.Algorithms() {
+		if got := batchAlgorithm(name); got != name {
+			t.Errorf("batchAlgorithm(%q) = %q; want %q", name, got, name)
+		}
+	}
+
+	// 批量处理不支持直接处理JPEG数据的算法，也不能留空，否则每个文件都会失败
+	want := steganography.Algorithms()[0]
+	for _, name := range append(steganography.JPEGAlgorithms(), "") {
+		if got := batchAlgorithm(name); got != want {
+			t.Errorf("batchAlgorithm(%q) = %q; want %q", name, got, want)
+		}
+		if _, err := steganography.New(batchAlgorithm(name)); err != nil {
+			t.Errorf("New(batchAlgorithm(%q)) error = %v", name, err)
+		}
+	}
+}
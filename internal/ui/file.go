@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	steganography "steganography-tool/internal/stegnaography"
+)
+
+// 选择要隐藏的文件，选择后隐藏文件而不是输入的文本
+func (s *SteganoUI) selectFile() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, s.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("无法读取文件: %v", err), s.window)
+			return
+		}
+
+		s.file = steganography.NewFile(reader.URI().Name(), data)
+		s.fileLabel.SetText(describeFile(s.file))
+		s.textInput.Disable()
+		s.updateTextLength()
+	}, s.window)
+}
+
+// 清除已选择的文件，恢复隐藏文本
+func (s *SteganoUI) clearFile() {
+	s.file = nil
+	s.fileLabel.SetText("")
+	s.textInput.Enable()
+	s.updateTextLength()
+}
+
+// 按原文件名保存提取出的文件
+func (s *SteganoUI) saveExtractedFile(f *steganography.File) {
+	fd := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, s.window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if _, err := writer.Write(f.Data); err != nil {
+			dialog.ShowError(fmt.Errorf("保存失败: %v", err), s.window)
+			return
+		}
+		dialog.ShowInformation("成功", "文件已成功保存", s.window)
+	}, s.window)
+	fd.SetFileName(f.Name)
+	fd.Show()
+}
+
+// 返回文件名、内容类型和大小的描述
+func describeFile(f *steganography.File) string {
+	return fmt.Sprintf("%s（%s，%d 字节）", f.Name, f.ContentType, len(f.Data))
+}